@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/saikumaradapa/Connection-Sphere/internal/mailer"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+// getCurrentUserHandler godoc
+//
+//	@Summary		Fetches the authenticated user
+//	@Description	Fetches the private profile of the authenticated user, email included
+//	@Tags			account
+//	@Produce		json
+//	@Success		200	{object}	store.PrivateProfile
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [get]
+func (app *application) getCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	app.privateProfileResponse(w, r, user)
+}
+
+type UpdateAccountPayload struct {
+	Username    *string `json:"username" validate:"omitempty,min=1,max=100"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=100"`
+	Bio         *string `json:"bio" validate:"omitempty,max=500"`
+	AvatarURL   *string `json:"avatar_url" validate:"omitempty,http_url,max=500"`
+	Website     *string `json:"website" validate:"omitempty,http_url,max=500"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+}
+
+// updateCurrentUserHandler godoc
+//
+//	@Summary		Updates the authenticated user
+//	@Description	Updates the profile of the authenticated user, omitted fields are left unchanged
+//	@Tags			account
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateAccountPayload	true	"Profile fields"
+//	@Success		200		{object}	store.PrivateProfile
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Username already taken"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	var payload UpdateAccountPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Username != nil {
+		user.Username = *payload.Username
+	}
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.UpdateProfile(ctx, user); err != nil {
+		switch err {
+		case store.ErrDuplicateUsername:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUserCache(ctx, user.ID)
+
+	app.privateProfileResponse(w, r, user)
+}
+
+func (app *application) privateProfileResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	stats, err := app.store.Users.GetStats(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user.PrivateProfile(stats)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type ChangeEmailPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,max=75"`
+}
+
+// changeEmailHandler godoc
+//
+//	@Summary		Requests an email change
+//	@Description	Emails a confirmation link to the new address, the email changes once it is confirmed
+//	@Tags			account
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangeEmailPayload	true	"New email and current password"
+//	@Success		202		{string}	string				"Confirmation email sent"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Email already used"
+//	@Failure		429		{object}	error	"Too many failed attempts, see Retry-After"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/email [post]
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	var payload ChangeEmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.verifyCurrentPassword(w, r, user, payload.Password) {
+		return
+	}
+
+	ctx := r.Context()
+	plainToken := uuid.New().String()
+
+	if err := app.store.Users.CreateEmailChange(ctx, user.ID, payload.Email, hashToken(plainToken), app.config.mail.emailChangeExp); err != nil {
+		switch err {
+		case store.ErrDuplicateEmail:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username   string
+		ConfirmURL string
+		ExpiresIn  string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+		ExpiresIn:  app.config.mail.emailChangeExp.String(),
+	}
+
+	// sent to the new address, so only its owner can finish the change
+	if err := app.mailer.Send(mailer.EmailChangeTemplate, user.Username, payload.Email, vars, !isProdEnv); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, map[string]string{
+		"message": "a confirmation email has been sent to the new address",
+	}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// confirmEmailHandler godoc
+//
+//	@Summary		Confirms an email change
+//	@Description	Moves the account to the new email with the token sent to that address
+//	@Tags			account
+//	@Produce		json
+//	@Param			token	path	string	true	"Confirmation token"
+//	@Success		204		"Email changed"
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"Email already used"
+//	@Failure		500		{object}	error
+//	@Router			/users/email/confirm/{token} [put]
+func (app *application) confirmEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	ctx := r.Context()
+
+	userID, err := app.store.Users.ConfirmEmailChange(ctx, token)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidToken, store.ErrEmailChangeExpired:
+			app.badRequestResponse(w, r, err)
+		case store.ErrDuplicateEmail:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUserCache(ctx, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=75"`
+	NewPassword     string `json:"new_password" validate:"required,min=3,max=75"`
+}
+
+// changePasswordHandler godoc
+//
+//	@Summary		Changes the password
+//	@Description	Changes the password of the authenticated user. Every other session is logged out, a new token pair is returned for this one.
+//	@Tags			account
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangePasswordPayload	true	"Current and new password"
+//	@Success		200		{object}	TokenPair
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many failed attempts, see Retry-After"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/password [put]
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	var payload ChangePasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.verifyCurrentPassword(w, r, user, payload.CurrentPassword) {
+		return
+	}
+
+	ctx := r.Context()
+
+	// bumps the token generation, so the access token of this request stops working too
+	if err := app.store.Users.ChangePassword(ctx, user, payload.NewPassword); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.RefreshTokens.RevokeAllForUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUserCache(ctx, user.ID)
+
+	pair, err := app.issueTokenPair(ctx, user, uuid.New().String())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, pair); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type DeleteAccountPayload struct {
+	Password string `json:"password" validate:"required,max=75"`
+}
+
+// deleteAccountHandler godoc
+//
+//	@Summary		Deletes the account
+//	@Description	Deletes the authenticated user along with their posts, comments, follows and sessions
+//	@Tags			account
+//	@Accept			json
+//	@Param			payload	body	DeleteAccountPayload	true	"Current password"
+//	@Success		204		"Account deleted"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many failed attempts, see Retry-After"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	var payload DeleteAccountPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.verifyCurrentPassword(w, r, user, payload.Password) {
+		return
+	}
+
+	ctx := r.Context()
+
+	// posts, comments and every token table cascade on the user row
+	if err := app.store.Users.Delete(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUserCache(ctx, user.ID)
+	app.logger.Infow("account deleted", "user_id", user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyCurrentPassword re-authenticates the user before a sensitive change. Wrong passwords
+// count towards the login lockout, otherwise a stolen token would allow guessing the password.
+// It writes the error response and returns false when the password doesn't match.
+func (app *application) verifyCurrentPassword(w http.ResponseWriter, r *http.Request, user *store.User, password string) bool {
+	ctx := r.Context()
+	keys := loginKeys(user.Email, clientIP(r))
+
+	retryAfter, err := app.loginLockout(ctx, keys)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if retryAfter > 0 {
+		app.loginLockedResponse(w, r, retryAfter)
+		return false
+	}
+
+	// the user in the context may come from the cache, which doesn't keep the password hash
+	account, err := app.store.Users.GetByEmail(ctx, user.Email)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if err := account.Password.Compare(password); err != nil {
+		switch err {
+		case store.ErrInvalidCredentials:
+			app.loginFailedResponse(w, r, keys)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/saikumaradapa/Connection-Sphere/internal/mailer"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// resendActivationHandler godoc
+//
+//	@Summary		Resends the activation email
+//	@Description	Replaces the invitation of a registered but not yet activated user and emails a new activation link. The response is the same whether such a user exists or not.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendActivationPayload	true	"Account email"
+//	@Success		202		{string}	string					"Activation email sent if the account is pending activation"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/activation/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if allow, retryAfter := app.activationLimiter.Allow(normalizeEmail(payload.Email)); !allow {
+		app.rateLimitExceededResponse(w, r, retryAfter.String())
+		return
+	}
+
+	ctx := r.Context()
+	accepted := map[string]string{
+		"message": "if the account is pending activation, a new activation email has been sent",
+	}
+
+	plainToken := uuid.New().String()
+
+	// old invitations are replaced, only the newest link works
+	user, err := app.store.Users.RenewInvitation(ctx, payload.Email, hashToken(plainToken), app.config.mail.exp)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			// same answer for unknown and already active accounts to avoid enumeration
+			if err := app.jsonResponse(w, http.StatusAccepted, accepted); err != nil {
+				app.internalServerError(w, r, err)
+			}
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.sendActivationEmail(user, plainToken); err != nil {
+		app.logger.Errorw("error sending mail", "error", err, "user_id", user.ID)
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, accepted); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) sendActivationEmail(user *store.User, plainToken string) error {
+	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: activationURL,
+	}
+
+	return app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+var errSelfModeration = errors.New("admins can't change their own role or restrict their own account")
+
+// listUsersHandler godoc
+//
+//	@Summary		Lists users
+//	@Description	Lists and searches every user, inactive, suspended and banned ones included
+//	@Tags			admin
+//	@Produce		json
+//	@Param			search	query		string	false	"Matches username or email"
+//	@Param			role	query		string	false	"Role name"
+//	@Param			status	query		string	false	"active, inactive, suspended or banned"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users [get]
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	uq := store.UserSearchQuery{
+		Limit:  50,
+		Offset: 0,
+	}
+	uq, err := uq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(uq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	users, err := app.store.Users.Search(r.Context(), uq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type UpdateUserRolePayload struct {
+	Role string `json:"role" validate:"required,max=255"`
+}
+
+// updateUserRoleHandler godoc
+//
+//	@Summary		Changes the role of a user
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path	int						true	"User ID"
+//	@Param			payload	body	UpdateUserRolePayload	true	"Role name"
+//	@Success		204		"Role changed"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/role [patch]
+func (app *application) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.moderatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var payload UpdateUserRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	role, err := app.store.Roles.GetByName(ctx, payload.Role)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errors.New("unknown role"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Users.SetRole(ctx, userID, role.ID); err != nil {
+		app.moderationErrorResponse(w, r, err)
+		return
+	}
+
+	app.invalidateUserCache(ctx, userID)
+	app.logger.Infow("user role changed", "user_id", userID, "role", role.Name, "by", adminID(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type SuspendUserPayload struct {
+	Until  time.Time `json:"until" validate:"required"`
+	Reason string    `json:"reason" validate:"required,max=500"`
+}
+
+// suspendUserHandler godoc
+//
+//	@Summary		Suspends a user
+//	@Description	Blocks the user until the given time and ends their sessions
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path	int					true	"User ID"
+//	@Param			payload	body	SuspendUserPayload	true	"Suspension"
+//	@Success		204		"User suspended"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/suspend [post]
+func (app *application) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.moderatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var payload SuspendUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !payload.Until.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("suspension must end in the future"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.Suspend(ctx, userID, payload.Until, payload.Reason); err != nil {
+		app.moderationErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.revokeUserSessions(ctx, userID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user suspended", "user_id", userID, "until", payload.Until, "by", adminID(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type BanUserPayload struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// banUserHandler godoc
+//
+//	@Summary		Bans a user
+//	@Description	Blocks the user permanently and ends their sessions
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path	int				true	"User ID"
+//	@Param			payload	body	BanUserPayload	true	"Ban"
+//	@Success		204		"User banned"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/ban [post]
+func (app *application) banUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.moderatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var payload BanUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.Ban(ctx, userID, payload.Reason); err != nil {
+		app.moderationErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.revokeUserSessions(ctx, userID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user banned", "user_id", userID, "by", adminID(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reactivateUserHandler godoc
+//
+//	@Summary		Reactivates a user
+//	@Description	Lifts suspensions and bans, and activates users that never accepted their invitation
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		"User reactivated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/reactivate [post]
+func (app *application) reactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.moderatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.Reactivate(ctx, userID); err != nil {
+		app.moderationErrorResponse(w, r, err)
+		return
+	}
+
+	app.invalidateUserCache(ctx, userID)
+	app.logger.Infow("user reactivated", "user_id", userID, "by", adminID(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// moderatedUserID reads the target user from the url, admins can't moderate themselves
+// so they can't lock every admin out by accident.
+func (app *application) moderatedUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID < 1 {
+		app.badRequestResponse(w, r, errors.New("invalid user id"))
+		return 0, false
+	}
+
+	if userID == adminID(r) {
+		app.badRequestResponse(w, r, errSelfModeration)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func adminID(r *http.Request) int64 {
+	admin, err := getUserFromCtx(r)
+	if err != nil {
+		return 0
+	}
+
+	return admin.ID
+}
+
+func (app *application) moderationErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case store.ErrNotFound:
+		app.notFoundResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -18,7 +19,10 @@ import (
 
 	"github.com/saikumaradapa/Connection-Sphere/docs" // This is required to generate Swagger docs
 	"github.com/saikumaradapa/Connection-Sphere/internal/auth"
+	"github.com/saikumaradapa/Connection-Sphere/internal/cursor"
 	"github.com/saikumaradapa/Connection-Sphere/internal/mailer"
+	"github.com/saikumaradapa/Connection-Sphere/internal/oidc"
+	"github.com/saikumaradapa/Connection-Sphere/internal/ranking"
 	"github.com/saikumaradapa/Connection-Sphere/internal/ratelimiter"
 	"github.com/saikumaradapa/Connection-Sphere/internal/store"
 	"github.com/saikumaradapa/Connection-Sphere/internal/store/cache"
@@ -33,6 +37,18 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+
+	// activationLimiter throttles activation email resends per email address
+	activationLimiter ratelimiter.Limiter
+
+	// oidcProvider is nil unless an external identity provider is configured
+	oidcProvider *oidc.Provider
+
+	// cursors signs the pagination cursors handed to clients
+	cursors *cursor.Signer
+
+	// ranker orders the sort=ranked feed
+	ranker *ranking.Ranker
 }
 
 type config struct {
@@ -45,6 +61,37 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiterConfig
+	activation  activationConfig
+	posts       postsConfig
+	pagination  paginationConfig
+	ranking     rankingConfig
+	explore     exploreConfig
+}
+
+type exploreConfig struct {
+	cacheMaxAge time.Duration // how long public listings may be cached
+}
+
+type rankingConfig struct {
+	strategy      string        // see ranking.New
+	candidates    int           // newest feed posts ranked for a request
+	signalsWindow time.Duration // interactions taken into account for affinity and interests
+}
+
+type paginationConfig struct {
+	cursorSecret string
+}
+
+type postsConfig struct {
+	publishInterval    time.Duration // how often scheduled posts are checked
+	celebrityThreshold int           // followers above which posts aren't pushed to timelines
+}
+
+type activationConfig struct {
+	resendLimit   int
+	resendWindow  time.Duration
+	gracePeriod   time.Duration // how long unactivated users are kept
+	sweepInterval time.Duration
 }
 
 type ratelimiterConfig struct {
@@ -61,15 +108,36 @@ type redisConfig struct {
 }
 
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	basic   basicConfig
+	token   tokenConfig
+	lockout lockoutConfig
+	oidc    oidcConfig
+}
+
+type oidcConfig struct {
+	enabled      bool
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string // must point to /v1/authentication/oidc/callback
+	scopes       []string
+}
+
+type lockoutConfig struct {
+	maxAttempts int
+	duration    time.Duration
+	maxDuration time.Duration
 }
 
 type tokenConfig struct {
-	secret   string
-	exp      time.Duration
-	issuer   string
-	audience string
+	algorithm   string
+	secret      string
+	keyRotation time.Duration
+	keySecret   string
+	exp         time.Duration
+	refreshExp  time.Duration
+	issuer      string
+	audience    string
 }
 
 type basicConfig struct {
@@ -78,9 +146,11 @@ type basicConfig struct {
 }
 
 type mailConfig struct {
-	sendGrid  sendGridConfig
-	fromEmail string
-	exp       time.Duration
+	sendGrid       sendGridConfig
+	fromEmail      string
+	exp            time.Duration
+	resetExp       time.Duration
+	emailChangeExp time.Duration
 }
 
 type sendGridConfig struct {
@@ -128,6 +198,9 @@ func (app *application) mount() http.Handler {
 		httpSwagger.URL("http://"+app.config.addr+"/swagger/doc.json"),
 	))
 
+	// Token verification keys for other services, at the well-known location
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
 	// API routes under /v1
 	r.Route("/v1", func(r chi.Router) {
 		// Operations
@@ -143,13 +216,75 @@ func (app *application) mount() http.Handler {
 				r.Use(app.postsContextMiddleware)
 
 				r.Get("/", app.getPostHandler)
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+				r.Patch("/", app.checkPostOwnership(store.PermissionPostsUpdateAny, app.updatePostHandler))
+				r.Delete("/", app.checkPostOwnership(store.PermissionPostsDeleteAny, app.deletePostHandler))
+
+				r.Get("/comments", app.listCommentsHandler)
+				r.Post("/comments", app.createCommentHandler)
+
+				r.Put("/reactions", app.reactToPostHandler)
+				r.Delete("/reactions", app.unreactToPostHandler)
+
+				r.Put("/bookmark", app.bookmarkPostHandler)
+				r.Delete("/bookmark", app.unbookmarkPostHandler)
+
+				r.Post("/repost", app.repostHandler)
+				r.Delete("/repost", app.deleteRepostHandler)
+
+				r.Post("/publish", app.checkPostOwnership(store.PermissionPostsUpdateAny, app.publishPostHandler))
+
+				r.Route("/revisions", func(r chi.Router) {
+					r.Get("/", app.checkPostOwnership(store.PermissionPostsUpdateAny, app.listRevisionsHandler))
+					r.Get("/diff", app.checkPostOwnership(store.PermissionPostsUpdateAny, app.diffRevisionsHandler))
+					r.Post("/{version}/restore", app.checkPostOwnership(store.PermissionPostsUpdateAny, app.restoreRevisionHandler))
+				})
 			})
 		})
 
+		r.Route("/comments/{commentID}", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.commentsContextMiddleware)
+
+			r.Get("/replies", app.listRepliesHandler)
+			r.Get("/thread", app.getThreadHandler)
+
+			r.Patch("/", app.checkCommentOwnership(store.PermissionCommentsUpdateAny, app.updateCommentHandler))
+			r.Delete("/", app.checkCommentOwnership(store.PermissionCommentsDeleteAny, app.deleteCommentHandler))
+		})
+
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Put("/email/confirm/{token}", app.confirmEmailHandler)
+
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
+				r.Get("/", app.getCurrentUserHandler)
+				r.Patch("/", app.updateCurrentUserHandler)
+				r.Get("/bookmarks", app.listBookmarksHandler)
+				r.Get("/drafts", app.listDraftsHandler)
+
+				// account security is out of reach of personal access tokens
+				r.Group(func(r chi.Router) {
+					r.Use(app.requireSession)
+
+					r.Delete("/", app.deleteAccountHandler)
+					r.Post("/email", app.changeEmailHandler)
+					r.Put("/password", app.changePasswordHandler)
+
+					r.Route("/mfa/totp", func(r chi.Router) {
+						r.Post("/", app.enrollTOTPHandler)
+						r.Post("/confirm", app.confirmTOTPHandler)
+						r.Post("/disable", app.disableTOTPHandler)
+					})
+
+					r.Route("/tokens", func(r chi.Router) {
+						r.Get("/", app.listPersonalTokensHandler)
+						r.Post("/", app.createPersonalTokenHandler)
+						r.Delete("/{tokenID}", app.revokePersonalTokenHandler)
+					})
+				})
+			})
 
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
@@ -167,10 +302,56 @@ func (app *application) mount() http.Handler {
 
 		})
 
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireSession)
+			r.Use(app.requirePermission(store.PermissionUsersManage))
+
+			r.Route("/users", func(r chi.Router) {
+				r.Get("/", app.listUsersHandler)
+
+				r.Route("/{userID}", func(r chi.Router) {
+					r.Patch("/role", app.updateUserRoleHandler)
+					r.Post("/reactivate", app.reactivateUserHandler)
+
+					r.Group(func(r chi.Router) {
+						r.Use(app.requirePermission(store.PermissionUsersBan))
+
+						r.Post("/suspend", app.suspendUserHandler)
+						r.Post("/ban", app.banUserHandler)
+					})
+				})
+			})
+		})
+
 		// Public routes
+		r.Get("/explore", app.exploreHandler)
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/trending", app.trendingTagsHandler)
+			r.Get("/{tag}/posts", app.tagPostsHandler)
+		})
+
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/mfa", app.verifyMFAHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
+			r.Post("/activation/resend", app.resendActivationHandler)
+
+			if app.oidcProvider != nil {
+				r.Get("/oidc/login", app.oidcLoginHandler)
+				r.Get("/oidc/callback", app.oidcCallbackHandler)
+			}
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
+				r.Post("/logout", app.logoutHandler)
+				r.Post("/logout/all", app.logoutAllHandler)
+			})
 		})
 
 	})
@@ -193,6 +374,14 @@ func (app *application) run(mux http.Handler) error {
 		IdleTimeout:  time.Minute,      // how long to keep idle connections open
 	}
 
+	// background jobs run alongside the server and stop with it
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	var jobs sync.WaitGroup
+	runJob(jobsCtx, &jobs, app.runSweeper)
+	runJob(jobsCtx, &jobs, app.runPublisher)
+
 	// channel to receive shutdown errors
 	shutdown := make(chan error)
 
@@ -230,7 +419,20 @@ func (app *application) run(mux http.Handler) error {
 		return err
 	}
 
+	// then let the background jobs finish their current run
+	stopJobs()
+	jobs.Wait()
+
 	app.logger.Infow("server has stopped", "addr", app.config.addr, "env", app.config.env)
 
 	return nil
 }
+
+// runJob runs fn in a goroutine until ctx is canceled, wg lets the shutdown wait for it.
+func runJob(ctx context.Context, wg *sync.WaitGroup, fn func(context.Context)) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fn(ctx)
+	}()
+}
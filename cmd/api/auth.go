@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
-	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/saikumaradapa/Connection-Sphere/internal/mailer"
 	"github.com/saikumaradapa/Connection-Sphere/internal/store"
 )
 
@@ -65,12 +66,8 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	plainToken := uuid.New().String()
 
-	// store the hashed token
-	hash := sha256.Sum256([]byte(plainToken))
-	hashToken := hex.EncodeToString(hash[:])
-
-	// store the user
-	err := app.store.Users.CreateAndInvite(ctx, user, hashToken, app.config.mail.exp)
+	// store the user with the hashed token
+	err := app.store.Users.CreateAndInvite(ctx, user, hashToken(plainToken), app.config.mail.exp)
 	if err != nil {
 		switch err {
 		case store.ErrDuplicateEmail:
@@ -87,19 +84,9 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		User:  user,
 		Token: plainToken,
 	}
-	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
-
-	isProdEnv := app.config.env == "production"
-	vars := struct {
-		Username      string
-		ActivationURL string
-	}{
-		Username:      user.Username,
-		ActivationURL: activationURL,
-	}
 
 	// send mail
-	err = app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+	err = app.sendActivationEmail(user, plainToken)
 	if err != nil {
 		app.logger.Errorw("error sending mail", "error", err, "user_id", user.ID)
 
@@ -128,14 +115,15 @@ type CreateUserTokenPayload struct {
 // createTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for a user
+//	@Description	Creates a short-lived access token and a refresh token for a user
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		200		{object}	TokenPair				"Token pair, or an MFAChallenge when the account has two-factor authentication enabled"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many failed attempts, see Retry-After"
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -150,10 +138,112 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	ctx := r.Context()
+	keys := loginKeys(payload.Email, clientIP(r))
+
+	// refuse early while the account or the client is locked out
+	retryAfter, err := app.loginLockout(ctx, keys)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if retryAfter > 0 {
+		app.loginLockedResponse(w, r, retryAfter)
+		return
+	}
+
+	// unknown emails and wrong passwords get the same answer to avoid enumeration
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
+			app.loginFailedResponse(w, r, keys)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := user.Password.Compare(payload.Password); err != nil {
+		switch err {
+		case store.ErrInvalidCredentials:
+			app.loginFailedResponse(w, r, keys)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.clearLoginFailures(ctx, payload.Email)
+
+	// accounts with a second factor only get a short-lived ticket for /authentication/mfa
+	totp, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if totp != nil && totp.Enabled {
+		app.mfaChallengeResponse(w, r, user)
+		return
+	}
+
+	pair, err := app.issueTokenPair(ctx, user, uuid.New().String())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, pair); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access token and a new refresh token. A refresh token can be used only once, reusing it revokes every token of that login.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		200		{object}	TokenPair			"Token pair"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	plainToken, next, err := app.newRefreshToken(0, "")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.RefreshTokens.Rotate(ctx, hashToken(payload.RefreshToken), next); err != nil {
+		switch err {
+		case store.ErrRefreshTokenReused:
+			app.logger.Warnw("refresh token reuse detected, token family revoked", "ip", clientIP(r))
+			app.unauthorizedErrorResponse(w, r, err)
+		case store.ErrInvalidToken, store.ErrRefreshTokenExpired:
 			app.unauthorizedErrorResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
@@ -161,8 +251,74 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// the user may have been deleted or deactivated since the login
+	user, err := app.store.Users.GetByID(ctx, next.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, store.ErrInvalidToken)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	accessToken, err := app.generateAccessToken(user, next.FamilyID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, app.newTokenPair(accessToken, plainToken)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+}
+
+func (app *application) newTokenPair(accessToken, refreshToken string) *TokenPair {
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}
+}
+
+// issueTokenPair starts a new session: a short-lived access token plus the first refresh token of the family.
+func (app *application) issueTokenPair(ctx context.Context, user *store.User, familyID string) (*TokenPair, error) {
+	plainToken, refreshToken, err := app.newRefreshToken(user.ID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.store.RefreshTokens.Create(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := app.generateAccessToken(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.newTokenPair(accessToken, plainToken), nil
+}
+
+// generateAccessToken signs an access token for the session identified by the refresh token family.
+// jti lets a single token be revoked, gen lets all tokens of the user be revoked at once.
+func (app *application) generateAccessToken(user *store.User, familyID string) (string, error) {
 	claims := jwt.MapClaims{
 		"sub": user.ID,
+		"typ": accessTokenType,
+		"jti": uuid.New().String(),
+		"gen": user.TokenGeneration,
+		"fid": familyID,
 		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
@@ -170,14 +326,35 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		"aud": app.config.auth.token.audience,
 	}
 
-	token, err := app.authenticator.GenerateToken(claims)
+	return app.authenticator.GenerateToken(claims)
+}
+
+// newRefreshToken returns the opaque token for the client and its hashed form for the store.
+func (app *application) newRefreshToken(userID int64, familyID string) (string, *store.RefreshToken, error) {
+	plainToken, err := generateOpaqueToken()
 	if err != nil {
-		app.internalServerError(w, r, err)
-		return
+		return "", nil, err
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, token); err != nil {
-		app.internalServerError(w, r, err)
-		return
+	return plainToken, &store.RefreshToken{
+		Token:    hashToken(plainToken),
+		UserID:   userID,
+		FamilyID: familyID,
+		Expiry:   time.Now().Add(app.config.auth.token.refreshExp),
+	}, nil
+}
+
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how every token handed out by email or to clients is stored: sha256, hex encoded.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+// bookmarkPostHandler godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post privately for the authenticated user, saving it again changes nothing
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204		"Post bookmarked"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Bookmarks.Add(r.Context(), user.ID, post.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unbookmarkPostHandler godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes a post from the bookmarks of the authenticated user, if it was saved
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204		"Bookmark removed"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *application) unbookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Bookmarks.Remove(r.Context(), user.ID, post.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listBookmarksHandler godoc
+//
+//	@Summary		Lists bookmarked posts
+//	@Description	Lists the posts saved by the authenticated user, most recently saved first. Sort, since and until apply to the time of saving.
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset, use cursor instead"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Header			200		{string}	Link	"Next page, rel=next"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks [get]
+func (app *application) listBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err = fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.readFeedCursor(&fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	bookmarks, err := app.store.Bookmarks.GetByUser(ctx, user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.addViewerMetadata(ctx, bookmarks, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	next, err := app.nextFeedCursor(fq, len(bookmarks), func(i int) store.FeedPosition {
+		return store.FeedPosition{CreatedAt: bookmarks[i].BookmarkedAt, ID: bookmarks[i].ID}
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.pageResponse(w, r, bookmarks, next); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
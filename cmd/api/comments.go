@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+type CreateCommentPayload struct {
+	Content  string `json:"content" validate:"required,max=1000"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gte=1"`
+}
+
+var errParentNotFound = errors.New("the parent comment doesn't exist on this post")
+
+// createCommentHandler godoc
+//
+//	@Summary		Comments a post
+//	@Description	Adds a comment to a post, or a reply to one of its comments when parent_id is set
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int						true	"Post ID"
+//	@Param			payload	body		CreateCommentPayload	true	"Comment payload"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	var payload CreateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	comment := &store.Comment{
+		PostID:   post.ID,
+		UserID:   user.ID,
+		ParentID: payload.ParentID,
+		Content:  payload.Content,
+		User: store.User{
+			ID:       user.ID,
+			Username: user.Username,
+		},
+	}
+
+	if payload.ParentID != nil {
+		parent, err := app.store.Comments.GetByID(ctx, *payload.ParentID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.badRequestResponse(w, r, errParentNotFound)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if parent.PostID != post.ID {
+			app.badRequestResponse(w, r, errParentNotFound)
+			return
+		}
+
+		if parent.Depth >= store.MaxCommentDepth {
+			app.badRequestResponse(w, r, store.ErrCommentTooDeep)
+			return
+		}
+
+		comment.Depth = parent.Depth + 1
+	}
+
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// listCommentsHandler godoc
+//
+//	@Summary		Lists the comments of a post
+//	@Description	Lists the top-level comments of a post with their reply_count, replies are loaded per comment
+//	@Tags			comments
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"asc or desc (default) by creation date"
+//	@Success		200		{object}	[]store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *application) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	cq, err := defaultCommentsQuery().Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, comments); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RepliesPage is a page of replies, NextCursor is empty on the last page.
+type RepliesPage struct {
+	Replies    []store.Comment `json:"replies"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// listRepliesHandler godoc
+//
+//	@Summary		Lists the replies of a comment
+//	@Description	Lists the direct replies of a comment, oldest first. Pass next_cursor back as cursor to load more.
+//	@Tags			comments
+//	@Produce		json
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		string	false	"Cursor from the previous page"
+//	@Success		200			{object}	RepliesPage
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID}/replies [get]
+func (app *application) listRepliesHandler(w http.ResponseWriter, r *http.Request) {
+	comment, err := getCommentFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	rq := store.RepliesQuery{
+		Limit:  20,
+		Cursor: 0,
+	}
+	rq, err = rq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(rq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	replies, err := app.store.Comments.GetReplies(r.Context(), []int64{comment.ID}, rq.Cursor, rq.Limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// one reply more than the limit comes back when the page isn't the last one
+	page := RepliesPage{Replies: replies}
+	if len(replies) > rq.Limit {
+		page.Replies = replies[:rq.Limit]
+		page.NextCursor = strconv.FormatInt(page.Replies[rq.Limit-1].ID, 10)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getThreadHandler godoc
+//
+//	@Summary		Fetches a comment thread
+//	@Description	Fetches a comment with its replies as a tree. Comments with replies left out carry a next_replies_cursor for their replies endpoint.
+//	@Tags			comments
+//	@Produce		json
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Param			depth		query		int	false	"Levels of replies, 3 by default"
+//	@Param			limit		query		int	false	"Replies per comment, 5 by default"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID}/thread [get]
+func (app *application) getThreadHandler(w http.ResponseWriter, r *http.Request) {
+	comment, err := getCommentFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	tq := store.ThreadQuery{
+		Depth: 3,
+		Limit: 5,
+	}
+	tq, err = tq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(tq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	thread, err := app.store.Comments.GetThread(r.Context(), comment.ID, tq.Depth, tq.Limit)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, thread); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// updateCommentHandler godoc
+//
+//	@Summary		Edits a comment
+//	@Description	Edits a comment and sets its edited_at marker
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			payload		body		UpdateCommentPayload	true	"Comment payload"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, err := getCommentFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	var payload UpdateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comment.Content = payload.Content
+
+	if err := app.store.Comments.Update(r.Context(), comment); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// deleteCommentHandler godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Deletes a comment along with its replies
+//	@Tags			comments
+//	@Produce		json
+//	@Param			commentID	path	int	true	"Comment ID"
+//	@Success		204			"Comment deleted (no content returned)"
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, err := getCommentFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Comments.Delete(r.Context(), comment.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkCommentOwnership lets the author of the comment through, other users need the "any" permission.
+func (app *application) checkCommentOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return app.checkOwnership(permission, func(r *http.Request) (int64, error) {
+		comment, err := getCommentFromCtx(r)
+		if err != nil {
+			return 0, err
+		}
+		return comment.UserID, nil
+	}, next)
+}
+
+func (app *application) commentsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		comment, err := app.store.Comments.GetByID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) (*store.Comment, error) {
+	comment, ok := r.Context().Value(commentCtx).(*store.Comment)
+	if !ok {
+		return nil, store.ErrCommentMissingInContext
+	}
+	return comment, nil
+}
+
+// defaultCommentsQuery is the first page of comments, newest first.
+func defaultCommentsQuery() store.PaginatedCommentsQuery {
+	return store.PaginatedCommentsQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+var (
+	errPublishAtInPast  = errors.New("publish_at must be in the future")
+	errAlreadyPublished = errors.New("the post is already published")
+)
+
+// listDraftsHandler godoc
+//
+//	@Summary		Lists drafts
+//	@Description	Lists the drafts and scheduled posts of the authenticated user
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset, use cursor instead"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{object}	[]store.Post
+//	@Header			200		{string}	Link	"Next page, rel=next"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/drafts [get]
+func (app *application) listDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err = fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.readFeedCursor(&fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	drafts, err := app.store.Posts.GetDrafts(r.Context(), user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	next, err := app.nextFeedCursor(fq, len(drafts), func(i int) store.FeedPosition {
+		return store.FeedPosition{CreatedAt: drafts[i].CreatedAt, ID: drafts[i].ID}
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.pageResponse(w, r, drafts, next); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type PublishPostPayload struct {
+	PublishAt *time.Time `json:"publish_at"` // schedules the post instead of publishing it now
+}
+
+// publishPostHandler godoc
+//
+//	@Summary		Publishes a draft
+//	@Description	Publishes a draft or scheduled post now, or (re)schedules it when publish_at is set
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int					true	"Post ID"
+//	@Param			payload	body		PublishPostPayload	false	"Publication time"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already published"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/publish [post]
+func (app *application) publishPostHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	var payload PublishPostPayload
+	if r.ContentLength != 0 {
+		if err := readJSON(w, r, &payload); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	if payload.PublishAt != nil && !payload.PublishAt.After(time.Now()) {
+		app.badRequestResponse(w, r, errPublishAtInPast)
+		return
+	}
+
+	if post.Status == store.PostStatusPublished {
+		app.conflictResponse(w, r, errAlreadyPublished)
+		return
+	}
+
+	if err := app.store.Posts.Publish(r.Context(), post, payload.PublishAt); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			// published in the meantime by the scheduler
+			app.conflictResponse(w, r, errAlreadyPublished)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// scheduled posts are pushed by the publisher once due
+	app.pushToTimelines(r.Context(), post)
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
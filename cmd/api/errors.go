@@ -1,7 +1,10 @@
 package main
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
@@ -62,3 +65,11 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 
 	writeError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
 }
+
+func (app *application) loginLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("login locked", "method", r.Method, "path", r.URL.Path, "retry_after", retryAfter.String())
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	writeError(w, http.StatusTooManyRequests, "too many failed login attempts, retry after: "+retryAfter.Round(time.Second).String())
+}
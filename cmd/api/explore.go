@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+// defaultTrendingWindow is the sliding window of popular posts and trending tags.
+const defaultTrendingWindow = 24 * time.Hour
+
+var (
+	errInvalidExploreSort = errors.New("sort must be recent or popular")
+	errInvalidTag         = errors.New("invalid tag")
+)
+
+// Explore listings are the same for everyone, they are public and cacheable. Reactions are
+// counted but nobody is the viewer, so reacted_by_me and bookmarked are always false.
+
+// exploreHandler godoc
+//
+//	@Summary		Explores public posts
+//	@Description	Lists the published posts of everyone, for visitors and users following no one. sort=recent pages with cursor and takes the feed filters, sort=popular ranks the posts of the window by reactions and comments and pages with offset.
+//	@Tags			explore
+//	@Produce		json
+//	@Param			sort	query		string	false	"Sort"	Enums(recent, popular)
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset, popular only"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page, recent only"
+//	@Param			window	query		string	false	"Window of popular posts, from 1h to 168h, default 24h"
+//	@Param			tags	query		string	false	"Comma separated, matches posts having any of them"
+//	@Param			search	query		string	false	"Search"
+//	@Param			since	query		string	false	"Since, RFC 3339 or 2006-01-02 15:04:05"
+//	@Param			until	query		string	false	"Until, RFC 3339 or 2006-01-02 15:04:05"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Header			200		{string}	Link	"Next page, rel=next"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/explore [get]
+func (app *application) exploreHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("sort") {
+	case "", "recent":
+		app.listPublicPosts(w, r, nil)
+	case "popular":
+		app.listPopularPosts(w, r)
+	default:
+		app.badRequestResponse(w, r, errInvalidExploreSort)
+	}
+}
+
+// tagPostsHandler godoc
+//
+//	@Summary		Lists the posts of a tag
+//	@Description	Lists the published posts having the tag, newest first unless sort=asc
+//	@Tags			explore
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Param			sort	query		string	false	"Sort"	Enums(asc, desc)
+//	@Param			search	query		string	false	"Search"
+//	@Param			since	query		string	false	"Since, RFC 3339 or 2006-01-02 15:04:05"
+//	@Param			until	query		string	false	"Until, RFC 3339 or 2006-01-02 15:04:05"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Header			200		{string}	Link	"Next page, rel=next"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) tagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	if tag == "" || len(tag) > 100 {
+		app.badRequestResponse(w, r, errInvalidTag)
+		return
+	}
+
+	app.listPublicPosts(w, r, []string{tag})
+}
+
+// trendingTagsHandler godoc
+//
+//	@Summary		Lists trending tags
+//	@Description	Lists the tags of the posts published in the sliding window, used by the most authors first
+//	@Tags			explore
+//	@Produce		json
+//	@Param			window	query		string	false	"Window, from 1h to 168h, default 24h"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/trending [get]
+func (app *application) trendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tq := store.TrendingQuery{
+		Limit:  10,
+		Window: defaultTrendingWindow,
+	}
+	tq, err := tq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(tq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tags, err := app.store.Posts.GetTrendingTags(r.Context(), tq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.publicCache(w)
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// listPublicPosts writes a page of public posts, restricted to the tags when given.
+func (app *application) listPublicPosts(w http.ResponseWriter, r *http.Request, tags []string) {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if fq.Sort == "recent" {
+		fq.Sort = "desc"
+	}
+
+	if tags != nil {
+		fq.Tags = tags
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.readFeedCursor(&fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	posts, err := app.store.Posts.GetPublic(ctx, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.addPublicMetadata(ctx, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	next, err := app.nextFeedCursor(fq, len(posts), func(i int) store.FeedPosition {
+		return store.FeedPosition{CreatedAt: posts[i].CreatedAt, ID: posts[i].ID}
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.publicCache(w)
+
+	if err := app.pageResponse(w, r, posts, next); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) listPopularPosts(w http.ResponseWriter, r *http.Request) {
+	tq := store.TrendingQuery{
+		Limit:  20,
+		Window: defaultTrendingWindow,
+	}
+	tq, err := tq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(tq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	posts, err := app.store.Posts.GetPopular(ctx, tq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.addPublicMetadata(ctx, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.publicCache(w)
+
+	if err := app.pageResponse(w, r, posts, ""); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// addPublicMetadata counts the reactions of the posts, with no viewer.
+func (app *application) addPublicMetadata(ctx context.Context, feed []store.PostWithMetadata) error {
+	posts := make([]*store.Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+
+	return app.attachReactions(ctx, posts, 0)
+}
+
+// publicCache lets clients and shared caches keep the response for the configured time.
+func (app *application) publicCache(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(app.config.explore.cacheMaxAge.Seconds())))
+}
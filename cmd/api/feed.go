@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/saikumaradapa/Connection-Sphere/internal/store"
@@ -9,19 +10,24 @@ import (
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches the published posts of the authenticated user and of the users they follow. sort=ranked orders them by relevance and pages with offset only, debug=true adds the score of each post for admins.
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
-//	@Param			since	query		string	false	"Since"
-//	@Param			until	query		string	false	"Until"
+//	@Param			since	query		string	false	"Since, RFC 3339 or 2006-01-02 15:04:05"
+//	@Param			until	query		string	false	"Until, RFC 3339 or 2006-01-02 15:04:05"
 //	@Param			limit	query		int		false	"Limit"
-//	@Param			offset	query		int		false	"Offset"
-//	@Param			sort	query		string	false	"Sort"
-//	@Param			tags	query		string	false	"Tags"
+//	@Param			offset	query		int		false	"Offset, use cursor instead"
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Param			sort	query		string	false	"Sort"	Enums(asc, desc, ranked)
+//	@Param			debug	query		bool	false	"Explain ranked scores, admins only"
+//	@Param			tags	query		string	false	"Comma separated, matches posts having any of them"
 //	@Param			search	query		string	false	"Search"
 //	@Success		200		{object}	[]store.PostWithMetadata
+//	@Header			200		{string}	Link	"Next page, rel=next"
 //	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error	"Debug mode without admin permission"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/feed [get]
@@ -38,21 +44,96 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// the ranked feed is built from the newest posts, filters apply the same way
+	ranked := fq.Sort == sortRanked
+	if ranked {
+		fq.Sort = "desc"
+	}
+
 	if err := Validate.Struct(fq); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
+	if err := app.readFeedCursor(&fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	viewer, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if ranked {
+		app.rankedFeedHandler(w, r, viewer, fq)
+		return
+	}
+
 	ctx := r.Context()
 
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(1), fq) // update hardcoded id
+	feed, err := app.getFeed(ctx, viewer.ID, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	if err := app.addViewerMetadata(ctx, feed, viewer.ID); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+
+	next, err := app.nextFeedCursor(fq, len(feed), func(i int) store.FeedPosition {
+		return store.FeedPosition{CreatedAt: feed[i].CreatedAt, ID: feed[i].ID}
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.pageResponse(w, r, feed, next); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getFeed reads the page from the materialized timeline when it can, from SQL otherwise.
+func (app *application) getFeed(ctx context.Context, viewerID int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, error) {
+	if app.timelinesEnabled() && servedByTimeline(fq) {
+		feed, ok, err := app.readTimeline(ctx, viewerID, fq)
+		switch {
+		case err != nil:
+			app.logger.Warnw("timeline: falling back to the database", "user_id", viewerID, "error", err)
+		case ok:
+			return feed, nil
+		}
+	}
+
+	return app.store.Posts.GetUserFeed(ctx, viewerID, fq)
+}
+
+// addViewerMetadata fills what depends on the viewer in feed items: reactions and bookmarks.
+func (app *application) addViewerMetadata(ctx context.Context, feed []store.PostWithMetadata, viewerID int64) error {
+	posts := make([]*store.Post, len(feed))
+	ids := make([]int64, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+		ids[i] = feed[i].ID
+	}
+
+	if err := app.attachReactions(ctx, posts, viewerID); err != nil {
+		return err
+	}
+
+	bookmarked, err := app.store.Bookmarks.GetBookmarked(ctx, viewerID, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range feed {
+		feed[i].Bookmarked = bookmarked[feed[i].ID]
+	}
+
+	return nil
 }
@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -44,3 +46,23 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 
 	return writeJSON(w, status, &envelope{Data: data})
 }
+
+// pageResponse writes a page of a listing. The cursor of the next page is added to the
+// envelope and to a Link header, it is empty on the last page.
+func (app *application) pageResponse(w http.ResponseWriter, r *http.Request, data any, nextCursor string) error {
+	type envelope struct {
+		Data       any    `json:"data"`
+		NextCursor string `json:"next_cursor,omitempty"`
+	}
+
+	if nextCursor != "" {
+		q := r.URL.Query()
+		q.Set("cursor", nextCursor)
+		q.Del("offset")
+
+		next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+
+	return writeJSON(w, http.StatusOK, &envelope{Data: data, NextCursor: nextCursor})
+}
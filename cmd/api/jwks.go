@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/saikumaradapa/Connection-Sphere/internal/auth"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+// jwksHandler godoc
+//
+//	@Summary		Publishes the token verification keys
+//	@Description	JSON Web Key Set of the public keys that verify Connection Sphere access tokens. Only available with an asymmetric JWT_ALGORITHM.
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKSet
+//	@Failure		404	{object}	error
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	publisher, ok := app.authenticator.(auth.KeyPublisher)
+	if !ok {
+		app.notFoundResponse(w, r, errors.New("tokens are signed with a shared secret, no public keys to publish"))
+		return
+	}
+
+	// verifiers refetch the set when they meet an unknown kid, so a short cache is enough
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	// the JWKS format is standard, it is not wrapped in the data envelope
+	if err := writeJSON(w, http.StatusOK, publisher.JWKS()); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// signingKeyStore keeps the asymmetric signing keys in the database.
+type signingKeyStore struct {
+	keys interface {
+		GetByAlgorithm(ctx context.Context, algorithm string, retiredSince time.Time) ([]store.SigningKey, error)
+		Rotate(ctx context.Context, key *store.SigningKey, dueBefore, retiredBefore time.Time) error
+	}
+}
+
+func (s signingKeyStore) Keys(ctx context.Context, algorithm string, retiredSince time.Time) ([]auth.StoredKey, error) {
+	keys, err := s.keys.GetByAlgorithm(ctx, algorithm, retiredSince)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make([]auth.StoredKey, len(keys))
+	for i, k := range keys {
+		stored[i] = auth.StoredKey(k)
+	}
+
+	return stored, nil
+}
+
+func (s signingKeyStore) Rotate(ctx context.Context, key auth.StoredKey, dueBefore, retiredBefore time.Time) error {
+	k := store.SigningKey(key)
+	return s.keys.Rotate(ctx, &k, dueBefore, retiredBefore)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"runtime"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -12,9 +13,12 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
 	"github.com/saikumaradapa/Connection-Sphere/internal/auth"
+	"github.com/saikumaradapa/Connection-Sphere/internal/cursor"
 	"github.com/saikumaradapa/Connection-Sphere/internal/db"
 	"github.com/saikumaradapa/Connection-Sphere/internal/env"
 	"github.com/saikumaradapa/Connection-Sphere/internal/mailer"
+	"github.com/saikumaradapa/Connection-Sphere/internal/oidc"
+	"github.com/saikumaradapa/Connection-Sphere/internal/ranking"
 	"github.com/saikumaradapa/Connection-Sphere/internal/ratelimiter"
 	"github.com/saikumaradapa/Connection-Sphere/internal/store"
 	"github.com/saikumaradapa/Connection-Sphere/internal/store/cache"
@@ -67,8 +71,10 @@ func main() {
 		},
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
-			exp:       time.Hour * 3, // 3 days
-			fromEmail: env.GetString("FROM_EMAIL", ""),
+			exp:            time.Hour * 3, // 3 days
+			resetExp:       env.GetDuration("PASSWORD_RESET_EXPIRATION", time.Hour),
+			emailChangeExp: env.GetDuration("EMAIL_CHANGE_EXPIRATION", time.Hour*24),
+			fromEmail:      env.GetString("FROM_EMAIL", ""),
 			sendGrid: sendGridConfig{
 				apiKey: env.GetString("SENDGRID_API_KEY", ""),
 			},
@@ -79,10 +85,27 @@ func main() {
 				password: env.GetString("BASIC_AUTH_PASSWORD", "password"),
 			},
 			token: tokenConfig{
-				secret:   env.GetString("JWT_SECRET", "supersecret"),
-				exp:      env.GetDuration("JWT_EXPIRATION", time.Hour*24), // default 1 day
-				issuer:   env.GetString("JWT_ISSUER", "connection-sphere"),
-				audience: env.GetString("JWT_AUDIENCE", "connection-sphere-clients"),
+				algorithm:   env.GetString("JWT_ALGORITHM", "HS256"), // HS256, RS256 or EdDSA
+				secret:      env.GetString("JWT_SECRET", "supersecret"),
+				keyRotation: env.GetDuration("JWT_KEY_ROTATION", time.Hour*24),           // asymmetric algorithms only
+				keySecret:   env.GetString("JWT_KEY_SECRET", "keysecret"),                // encrypts the stored asymmetric keys
+				exp:         env.GetDuration("JWT_EXPIRATION", time.Minute*15),           // default 15 minutes
+				refreshExp:  env.GetDuration("REFRESH_TOKEN_EXPIRATION", time.Hour*24*7), // default 7 days
+				issuer:      env.GetString("JWT_ISSUER", "connection-sphere"),
+				audience:    env.GetString("JWT_AUDIENCE", "connection-sphere-clients"),
+			},
+			lockout: lockoutConfig{
+				maxAttempts: env.GetInt("LOGIN_MAX_ATTEMPTS", 5),
+				duration:    env.GetDuration("LOGIN_LOCKOUT_DURATION", time.Minute),
+				maxDuration: env.GetDuration("LOGIN_LOCKOUT_MAX_DURATION", time.Hour),
+			},
+			oidc: oidcConfig{
+				enabled:      env.GetString("OIDC_ISSUER", "") != "",
+				issuer:       env.GetString("OIDC_ISSUER", ""),
+				clientID:     env.GetString("OIDC_CLIENT_ID", ""),
+				clientSecret: env.GetString("OIDC_CLIENT_SECRET", ""),
+				redirectURL:  env.GetString("OIDC_REDIRECT_URL", "http://localhost:3030/v1/authentication/oidc/callback"),
+				scopes:       strings.Fields(env.GetString("OIDC_SCOPES", "openid email profile")),
 			},
 		},
 		rateLimiter: ratelimiterConfig{
@@ -90,6 +113,27 @@ func main() {
 			timeFrame:            env.GetDuration("RATE_LIMIT_TIMEFRAME", time.Minute),
 			enabled:              env.GetBool("RATE_LIMIT_ENABLED", false), // default disabled
 		},
+		activation: activationConfig{
+			resendLimit:   env.GetInt("ACTIVATION_RESEND_LIMIT", 3),
+			resendWindow:  env.GetDuration("ACTIVATION_RESEND_WINDOW", time.Hour),
+			gracePeriod:   env.GetDuration("UNACTIVATED_USER_GRACE_PERIOD", time.Hour*24*7), // default 7 days
+			sweepInterval: env.GetDuration("SWEEPER_INTERVAL", time.Hour),
+		},
+		posts: postsConfig{
+			publishInterval:    env.GetDuration("POSTS_PUBLISH_INTERVAL", time.Minute),
+			celebrityThreshold: env.GetInt("TIMELINE_CELEBRITY_THRESHOLD", 10000),
+		},
+		pagination: paginationConfig{
+			cursorSecret: env.GetString("CURSOR_SECRET", "cursorsecret"),
+		},
+		ranking: rankingConfig{
+			strategy:      env.GetString("FEED_RANKING_STRATEGY", "weighted"), // weighted or chronological
+			candidates:    env.GetInt("FEED_RANKING_CANDIDATES", 500),
+			signalsWindow: env.GetDuration("FEED_RANKING_SIGNALS_WINDOW", time.Hour*24*30), // default 30 days
+		},
+		explore: exploreConfig{
+			cacheMaxAge: env.GetDuration("EXPLORE_CACHE_MAX_AGE", time.Minute),
+		},
 	}
 
 	// Logger configuration
@@ -124,6 +168,12 @@ func main() {
 		log.Printf("Connected to Redis DB at %s", cfg.redisCfg.addr)
 	}
 
+	// Activation email resends are throttled per email address
+	activationLimiter := ratelimiter.NewFixedWindowRateLimiter(
+		cfg.activation.resendLimit,
+		cfg.activation.resendWindow,
+	)
+
 	// Rate limiter
 	ratelimiter := ratelimiter.NewFixedWindowRateLimiter(
 		cfg.rateLimiter.requestsPerTimeFrame,
@@ -132,14 +182,57 @@ func main() {
 
 	store := store.NewStorage(db)
 	cacheStore := cache.NewRedisStorage(rdb)
+	if !cfg.redisCfg.enabled {
+		// role permissions are read on every authorized request, keep them in process instead
+		cacheStore.Permissions = cache.NewMemoryPermissionStore()
+	}
 
 	mailer := mailer.NewSendgrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
 
-	jwtAuthenticator := auth.NewJWTAuthenticator(
-		cfg.auth.token.secret,
-		cfg.auth.token.audience,
-		cfg.auth.token.issuer,
-	)
+	var jwtAuthenticator auth.Authenticator
+	switch cfg.auth.token.algorithm {
+	case auth.AlgorithmRS256, auth.AlgorithmEdDSA:
+		if cfg.auth.token.keySecret == "keysecret" && cfg.env == "production" {
+			log.Fatal("JWT_KEY_SECRET must be set in production")
+		}
+
+		// retired keys must outlive the longest lived token they signed
+		retention := max(cfg.auth.token.exp, mfaPendingTokenExp)
+
+		jwtAuthenticator, err = auth.NewKeySetAuthenticator(
+			cfg.auth.token.algorithm,
+			cfg.auth.token.keyRotation,
+			retention,
+			cfg.auth.token.audience,
+			cfg.auth.token.issuer,
+			signingKeyStore{store.SigningKeys},
+			cfg.auth.token.keySecret,
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case auth.AlgorithmHS256:
+		if cfg.auth.token.secret == "supersecret" && cfg.env == "production" {
+			log.Fatal("JWT_SECRET must be set in production")
+		}
+
+		jwtAuthenticator = auth.NewJWTAuthenticator(
+			cfg.auth.token.secret,
+			cfg.auth.token.audience,
+			cfg.auth.token.issuer,
+		)
+	default:
+		log.Fatalf("unsupported JWT_ALGORITHM %q, use HS256, RS256 or EdDSA", cfg.auth.token.algorithm)
+	}
+
+	if cfg.pagination.cursorSecret == "cursorsecret" && cfg.env == "production" {
+		log.Fatal("CURSOR_SECRET must be set in production")
+	}
+
+	rankingStrategy, err := ranking.New(cfg.ranking.strategy)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	app := &application{
 		config:        cfg,
@@ -149,6 +242,21 @@ func main() {
 		mailer:        mailer,
 		authenticator: jwtAuthenticator,
 		rateLimiter:   ratelimiter,
+
+		activationLimiter: activationLimiter,
+		cursors:           cursor.NewSigner(cfg.pagination.cursorSecret),
+		ranker:            ranking.NewRanker(rankingStrategy, nil),
+	}
+
+	// Sign in with an external OpenID Connect provider
+	if cfg.auth.oidc.enabled {
+		app.oidcProvider = oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.auth.oidc.issuer,
+			ClientID:     cfg.auth.oidc.clientID,
+			ClientSecret: cfg.auth.oidc.clientSecret,
+			RedirectURL:  cfg.auth.oidc.redirectURL,
+			Scopes:       cfg.auth.oidc.scopes,
+		}, nil)
 	}
 
 	// Metrics collected
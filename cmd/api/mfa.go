@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/saikumaradapa/Connection-Sphere/internal/auth"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+const (
+	accessTokenType     = "access"
+	mfaPendingTokenType = "mfa_pending"
+	mfaPendingTokenExp  = 5 * time.Minute
+
+	totpIssuer        = "Connection Sphere"
+	recoveryCodeCount = 10
+)
+
+var errInvalidMFACode = errors.New("invalid two-factor code")
+
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"` // seconds left to send the code
+}
+
+// mfaChallengeResponse answers a correct password of an account with a second factor:
+// instead of a session it hands out an mfa_pending ticket to exchange on /authentication/mfa.
+func (app *application) mfaChallengeResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	claims := jwt.MapClaims{
+		"sub": user.ID,
+		"typ": mfaPendingTokenType,
+		"jti": uuid.New().String(),
+		"gen": user.TokenGeneration,
+		"exp": time.Now().Add(mfaPendingTokenExp).Unix(),
+		"iat": time.Now().Unix(),
+		"nbf": time.Now().Unix(),
+		"iss": app.config.auth.token.issuer,
+		"aud": app.config.auth.token.audience,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	challenge := MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(mfaPendingTokenExp.Seconds()),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, challenge); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type VerifyMFAPayload struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=20"`
+}
+
+// verifyMFAHandler godoc
+//
+//	@Summary		Completes a two-factor login
+//	@Description	Exchanges the mfa_pending ticket returned by /authentication/token and a TOTP or recovery code for a token pair
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		VerifyMFAPayload	true	"Ticket and code"
+//	@Success		200		{object}	TokenPair			"Token pair"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many failed attempts, see Retry-After"
+//	@Failure		500		{object}	error
+//	@Router			/authentication/mfa [post]
+func (app *application) verifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyMFAPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	jwtToken, err := app.authenticator.ValidateToken(payload.MFAToken)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid token: %v", err))
+		return
+	}
+
+	claims := jwtToken.Claims.(jwt.MapClaims)
+	if typ, _ := claims["typ"].(string); typ != mfaPendingTokenType {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid token: not an mfa token"))
+		return
+	}
+
+	userID, err := subjectFromClaims(claims)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	// the ticket is single-use
+	jti, _ := claims["jti"].(string)
+	revoked, err := app.isTokenRevoked(ctx, jti)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if revoked {
+		app.unauthorizedErrorResponse(w, r, errTokenRevoked)
+		return
+	}
+
+	keys := map[string]string{
+		store.LoginScopeMFA: strconv.FormatInt(userID, 10),
+		store.LoginScopeIP:  clientIP(r),
+	}
+
+	retryAfter, err := app.loginLockout(ctx, keys)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if retryAfter > 0 {
+		app.loginLockedResponse(w, r, retryAfter)
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, store.ErrInvalidToken)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if gen, ok := claims["gen"].(float64); !ok || int(gen) != user.TokenGeneration {
+		app.unauthorizedErrorResponse(w, r, errTokenRevoked)
+		return
+	}
+
+	totp, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, store.ErrMFANotEnabled)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	ok, err := app.verifySecondFactor(ctx, totp, payload.Code)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.loginFailedResponse(w, r, keys)
+		return
+	}
+
+	if err := app.store.LoginAttempts.Reset(ctx, store.LoginScopeMFA, keys[store.LoginScopeMFA]); err != nil {
+		app.logger.Warnw("failed to reset mfa attempts", "user_id", user.ID, "err", err)
+	}
+
+	if err := app.revokeAccessToken(ctx, claims); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	pair, err := app.issueTokenPair(ctx, user, uuid.New().String())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, pair); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// enrollTOTPHandler godoc
+//
+//	@Summary		Starts the TOTP enrollment
+//	@Description	Generates a new TOTP secret for the authenticated user. The factor is only enabled after the first code is confirmed.
+//	@Tags			mfa
+//	@Produce		json
+//	@Success		200	{object}	TOTPEnrollment	"Secret and otpauth:// provisioning URI"
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error	"Two-factor authentication already enabled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mfa/totp [post]
+func (app *application) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.MFA.SetTOTPSecret(r.Context(), user.ID, secret); err != nil {
+		switch err {
+		case store.ErrMFAAlreadyEnabled:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(secret, totpIssuer, user.Email),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type TOTPCodePayload struct {
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// confirmTOTPHandler godoc
+//
+//	@Summary		Confirms the TOTP enrollment
+//	@Description	Enables the TOTP factor with the first code of the authenticator app and returns one-time recovery codes. They are shown only once.
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TOTPCodePayload	true	"Current TOTP code"
+//	@Success		200		{object}	RecoveryCodes
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Two-factor authentication already enabled"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mfa/totp/confirm [post]
+func (app *application) confirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	var payload TOTPCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	totp, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errors.New("no two-factor enrollment in progress"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if totp.Enabled {
+		app.conflictResponse(w, r, store.ErrMFAAlreadyEnabled)
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(totp.Secret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestResponse(w, r, errInvalidMFACode)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+
+	if err := app.store.MFA.EnableTOTP(ctx, user.ID, step, hashes); err != nil {
+		switch err {
+		case store.ErrMFAAlreadyEnabled:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, RecoveryCodes{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// disableTOTPHandler godoc
+//
+//	@Summary		Disables TOTP
+//	@Description	Removes the TOTP factor and its recovery codes, a current TOTP or recovery code is required
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	TOTPCodePayload	true	"TOTP or recovery code"
+//	@Success		204		"Two-factor authentication disabled (no content returned)"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mfa/totp/disable [post]
+func (app *application) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	var payload TOTPCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	totp, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, store.ErrMFANotEnabled)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !totp.Enabled {
+		app.badRequestResponse(w, r, store.ErrMFANotEnabled)
+		return
+	}
+
+	ok, err := app.verifySecondFactor(ctx, totp, payload.Code)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.badRequestResponse(w, r, errInvalidMFACode)
+		return
+	}
+
+	if err := app.store.MFA.DisableTOTP(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+// Accepted codes are burnt, a TOTP code cannot be replayed within its time step.
+func (app *application) verifySecondFactor(ctx context.Context, totp *store.TOTP, code string) (bool, error) {
+	if !totp.Enabled {
+		return false, nil
+	}
+
+	var err error
+	if isTOTPCode(code) {
+		step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+
+		err = app.store.MFA.UseTOTPStep(ctx, totp.UserID, step)
+	} else {
+		err = app.store.MFA.UseRecoveryCode(ctx, totp.UserID, auth.HashRecoveryCode(code))
+	}
+
+	if err != nil {
+		switch err {
+		case store.ErrInvalidToken:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != auth.TOTPDigits {
+		return false
+	}
+
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
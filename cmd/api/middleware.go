@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -27,6 +28,19 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 		}
 
 		token := parts[1]
+
+		if strings.HasPrefix(token, personalTokenPrefix) {
+			user, err := app.authenticatePersonalToken(r, token)
+			if err != nil {
+				app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid token: %v", err))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userCtx, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid token: %v", err))
@@ -35,7 +49,13 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		claims := jwtToken.Claims.(jwt.MapClaims)
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+		// mfa_pending tickets only work on /authentication/mfa
+		if typ, _ := claims["typ"].(string); typ != accessTokenType {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid token: not an access token"))
+			return
+		}
+
+		userID, err := subjectFromClaims(claims)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
@@ -43,13 +63,37 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid token: missing jti"))
+			return
+		}
+
+		revoked, err := app.isTokenRevoked(ctx, jti)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if revoked {
+			app.unauthorizedErrorResponse(w, r, errTokenRevoked)
+			return
+		}
+
 		user, err := app.getUser(ctx, userID)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
+		// tokens issued before the last "log out everywhere" are no longer valid
+		if gen, ok := claims["gen"].(float64); !ok || int(gen) != user.TokenGeneration {
+			app.unauthorizedErrorResponse(w, r, errTokenRevoked)
+			return
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, claimsCtx, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -95,7 +139,20 @@ func (app *application) BasicAuthMiddleware() func(next http.Handler) http.Handl
 	}
 }
 
-func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+// checkPostOwnership lets the author of the post through, other users need the "any" permission.
+func (app *application) checkPostOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return app.checkOwnership(permission, func(r *http.Request) (int64, error) {
+		post, err := getPostFromCtx(r)
+		if err != nil {
+			return 0, err
+		}
+		return post.UserID, nil
+	}, next)
+}
+
+// checkOwnership lets the owner of a resource through, other users need the permission.
+// owner returns the id of the user owning the resource loaded by a context middleware.
+func (app *application) checkOwnership(permission string, owner func(*http.Request) (int64, error), next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, err := getUserFromCtx(r)
 		if err != nil {
@@ -103,20 +160,19 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 			return
 		}
 
-		post, err := getPostFromCtx(r)
+		ownerID, err := owner(r)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
-		// if it is the user's post
-		if post.UserID == user.ID {
+		// if it is the user's own resource
+		if ownerID == user.ID {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// role precedence check
-		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		allowed, err := app.hasPermission(r.Context(), user, permission)
 		if err != nil {
 			app.internalServerError(w, r, err)
 			return
@@ -131,13 +187,65 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 	})
 }
 
-func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
-	role, err := app.store.Roles.GetByName(ctx, roleName)
+// requirePermission only lets through users whose role grants every given permission.
+// It must run after AuthTokenMiddleware.
+func (app *application) requirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := getUserFromCtx(r)
+			if err != nil {
+				app.unauthorizedErrorResponse(w, r, err)
+				return
+			}
+
+			for _, permission := range permissions {
+				allowed, err := app.hasPermission(r.Context(), user, permission)
+				if err != nil {
+					app.internalServerError(w, r, err)
+					return
+				}
+
+				if !allowed {
+					app.forbiddenErrorResponse(w, r, fmt.Errorf("forbidden: missing permission %s", permission))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *application) hasPermission(ctx context.Context, user *store.User, permission string) (bool, error) {
+	permissions, err := app.rolePermissions(ctx, user.Role.ID)
 	if err != nil {
 		return false, err
 	}
 
-	return user.Role.Level >= role.Level, nil
+	return slices.Contains(permissions, permission), nil
+}
+
+// rolePermissions reads the permissions of a role through the cache.
+func (app *application) rolePermissions(ctx context.Context, roleID int64) ([]string, error) {
+	permissions, err := app.cacheStore.Permissions.Get(ctx, roleID)
+	if err != nil {
+		app.logger.Warnw("cache error", "role_id", roleID, "err", err)
+	}
+
+	if permissions != nil {
+		return permissions, nil
+	}
+
+	permissions, err = app.store.Roles.GetPermissions(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStore.Permissions.Set(ctx, roleID, permissions); err != nil {
+		app.logger.Warnw("cache error", "role_id", roleID, "err", err)
+	}
+
+	return permissions, nil
 }
 
 func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
@@ -171,6 +279,19 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 	return user, nil
 }
 
+func subjectFromClaims(claims jwt.MapClaims) (int64, error) {
+	return strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+}
+
+// isTokenRevoked checks the jti denylist, in Redis when it is enabled and in Postgres otherwise.
+func (app *application) isTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.RevokedTokens.IsRevoked(ctx, jti)
+	}
+
+	return app.cacheStore.Tokens.IsRevoked(ctx, jti)
+}
+
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.rateLimiter.enabled {
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saikumaradapa/Connection-Sphere/internal/oidc"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+const (
+	// oidcFlowCookie keeps state, nonce and the PKCE verifier between the login redirect and the callback
+	oidcFlowCookie     = "oidc_flow"
+	oidcFlowCookiePath = "/v1/authentication/oidc"
+	oidcFlowExp        = 10 * time.Minute
+
+	// usernames are derived from the provider profile, taken ones get a random suffix
+	oidcUsernameAttempts = 3
+)
+
+var (
+	errOIDCStateMismatch = errors.New("oidc login state is missing or doesn't match, start the login again")
+	errOIDCMissingEmail  = errors.New("the identity provider didn't share an email address")
+	errOIDCUnverified    = errors.New("the identity provider didn't verify your email address, verify it there or register with a password")
+)
+
+// oidcLoginHandler godoc
+//
+//	@Summary		Starts an OpenID Connect login
+//	@Description	Redirects to the configured identity provider using the authorization code flow with PKCE
+//	@Tags			authentication
+//	@Success		302	"Redirect to the identity provider"
+//	@Failure		500	{object}	error
+//	@Router			/authentication/oidc/login [get]
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var flow [3]string // state, nonce, verifier
+	for i := range flow {
+		value, err := oidc.RandomString()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		flow[i] = value
+	}
+
+	authURL, err := app.oidcProvider.AuthCodeURL(r.Context(), flow[0], flow[1], flow[2])
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    strings.Join(flow[:], "."),
+		Path:     oidcFlowCookiePath,
+		MaxAge:   int(oidcFlowExp.Seconds()),
+		HttpOnly: true,
+		Secure:   app.config.env == "production",
+		SameSite: http.SameSiteLaxMode, // sent on the top level redirect back from the provider
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallbackHandler godoc
+//
+//	@Summary		Completes an OpenID Connect login
+//	@Description	Exchanges the authorization code, verifies the ID token and logs the linked user in. First time logins with an email verified by the provider provision an active user.
+//	@Tags			authentication
+//	@Produce		json
+//	@Param			code	query		string		true	"Authorization code"
+//	@Param			state	query		string		true	"State sent to the provider"
+//	@Success		200		{object}	TokenPair	"Token pair, or an MFAChallenge when the account has two-factor authentication enabled"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Email already used by an account that can't be linked"
+//	@Failure		500		{object}	error
+//	@Router			/authentication/oidc/callback [get]
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	// the flow values are single use
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Path:     oidcFlowCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   app.config.env == "production",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("identity provider error: %s %s", providerErr, query.Get("error_description")))
+		return
+	}
+
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		app.badRequestResponse(w, r, errOIDCStateMismatch)
+		return
+	}
+
+	flow := strings.Split(cookie.Value, ".")
+	state := query.Get("state")
+	if len(flow) != 3 || state == "" || subtle.ConstantTimeCompare([]byte(flow[0]), []byte(state)) != 1 {
+		app.badRequestResponse(w, r, errOIDCStateMismatch)
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		app.badRequestResponse(w, r, errors.New("authorization code is missing"))
+		return
+	}
+
+	ctx := r.Context()
+
+	rawIDToken, err := app.oidcProvider.Exchange(ctx, code, flow[2])
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	claims, err := app.oidcProvider.VerifyIDToken(ctx, rawIDToken, flow[1])
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	user, err := app.userFromIdentity(ctx, claims)
+	if err != nil {
+		switch err {
+		case errOIDCMissingEmail, errOIDCUnverified:
+			app.badRequestResponse(w, r, err)
+		case store.ErrDuplicateEmail, store.ErrDuplicateUsername, store.ErrIdentityAlreadyLinked:
+			app.conflictResponse(w, r, err)
+		case store.ErrNotFound:
+			// linked to a user that has since been deactivated
+			app.unauthorizedErrorResponse(w, r, store.ErrInvalidCredentials)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// a second factor is still required, the provider only replaced the password
+	totp, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if totp != nil && totp.Enabled {
+		app.mfaChallengeResponse(w, r, user)
+		return
+	}
+
+	pair, err := app.issueTokenPair(ctx, user, uuid.New().String())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, pair); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// userFromIdentity returns the user linked to the external account. Unknown accounts are
+// linked to the active user owning the same email, or provisioned as a new user, and only
+// when the provider verified the email: otherwise anyone could claim an address they don't own.
+func (app *application) userFromIdentity(ctx context.Context, claims *oidc.Claims) (*store.User, error) {
+	userID, err := app.store.Identities.GetUserID(ctx, claims.Issuer, claims.Subject)
+	switch err {
+	case nil:
+		return app.store.Users.GetByID(ctx, userID)
+	case store.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, errOIDCMissingEmail
+	}
+
+	identity := &store.Identity{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+
+	if !claims.EmailVerified {
+		return nil, errOIDCUnverified
+	}
+
+	user, err := app.store.Users.GetByEmail(ctx, claims.Email)
+	switch err {
+	case nil:
+		identity.UserID = user.ID
+		if err := app.store.Identities.Link(ctx, identity); err != nil {
+			return nil, err
+		}
+
+		app.logger.Infow("linked external identity", "user_id", user.ID, "issuer", claims.Issuer)
+		return user, nil
+	case store.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	return app.provisionUser(ctx, claims, identity)
+}
+
+func (app *application) provisionUser(ctx context.Context, claims *oidc.Claims, identity *store.Identity) (*store.User, error) {
+	// the account has no usable password until the user resets it
+	password, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+
+	base := oidcUsername(claims)
+
+	for attempt := 0; attempt < oidcUsernameAttempts; attempt++ {
+		username := base
+		if attempt > 0 {
+			username = fmt.Sprintf("%s_%s", base, uuid.New().String()[:6])
+		}
+
+		user := &store.User{
+			Username: username,
+			Email:    claims.Email,
+			Role: store.Role{
+				Name: "user",
+			},
+		}
+
+		if err := user.Password.Set(password); err != nil {
+			return nil, err
+		}
+
+		err := app.store.Users.CreateWithIdentity(ctx, user, identity)
+		switch err {
+		case nil:
+			app.logger.Infow("provisioned user from external identity", "user_id", user.ID, "issuer", claims.Issuer)
+			return user, nil
+		case store.ErrDuplicateUsername:
+			continue
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, store.ErrDuplicateUsername
+}
+
+// oidcUsername derives a username from the provider profile.
+func oidcUsername(claims *oidc.Claims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		default:
+			return -1
+		}
+	}, name)
+
+	if len(name) > 90 {
+		name = name[:90]
+	}
+
+	if name == "" {
+		name = "user"
+	}
+
+	return name
+}
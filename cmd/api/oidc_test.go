@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/saikumaradapa/Connection-Sphere/internal/auth"
+	"github.com/saikumaradapa/Connection-Sphere/internal/oidc"
+	"github.com/saikumaradapa/Connection-Sphere/internal/oidc/oidctest"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+// oidcTestDB keeps the users and identities the login flow reads and writes.
+type oidcTestDB struct {
+	mu         sync.Mutex
+	users      []*store.User
+	identities []*store.Identity
+}
+
+func newOIDCTestApp(t *testing.T) (*application, *oidctest.Server, *oidcTestDB) {
+	t.Helper()
+
+	server := oidctest.NewServer("client", "secret")
+	t.Cleanup(server.Close)
+
+	db := &oidcTestDB{}
+
+	app := &application{
+		config: config{
+			env: "development",
+			auth: authConfig{
+				token: tokenConfig{
+					exp:        15 * time.Minute,
+					refreshExp: time.Hour,
+					issuer:     "connection-sphere",
+					audience:   "connection-sphere",
+				},
+			},
+		},
+		store: store.Storage{
+			Users:         oidcTestUsers{db: db},
+			Identities:    oidcTestIdentities{db: db},
+			MFA:           oidcTestMFA{},
+			RefreshTokens: oidcTestRefreshTokens{},
+		},
+		logger:        zap.NewNop().Sugar(),
+		authenticator: auth.NewJWTAuthenticator("secret", "connection-sphere", "connection-sphere"),
+		oidcProvider: oidc.NewProvider(oidc.Config{
+			Issuer:       server.Issuer(),
+			ClientID:     "client",
+			ClientSecret: "secret",
+			RedirectURL:  "http://localhost:8080/v1/authentication/oidc/callback",
+		}, server.Client()),
+	}
+
+	return app, server, db
+}
+
+// oidcLogin runs the login redirect, the approval by the issuer and the callback.
+func oidcLogin(t *testing.T, app *application, server *oidctest.Server) *httptest.ResponseRecorder {
+	t.Helper()
+
+	login := httptest.NewRecorder()
+	app.oidcLoginHandler(login, httptest.NewRequest(http.MethodGet, "/v1/authentication/oidc/login", nil))
+
+	if login.Code != http.StatusFound {
+		t.Fatalf("login returned %d", login.Code)
+	}
+
+	client := server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	res, err := client.Get(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	callbackURL, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/authentication/oidc/callback?"+callbackURL.RawQuery, nil)
+	for _, c := range login.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	callback := httptest.NewRecorder()
+	app.oidcCallbackHandler(callback, req)
+
+	return callback
+}
+
+func TestOIDCFirstLoginProvisionsUser(t *testing.T) {
+	app, server, db := newOIDCTestApp(t)
+	server.Identity = oidctest.Identity{Subject: "42", Email: "ada@example.com", EmailVerified: true, PreferredUsername: "ada"}
+
+	res := oidcLogin(t, app, server)
+	if res.Code != http.StatusOK {
+		t.Fatalf("callback returned %d: %s", res.Code, res.Body)
+	}
+
+	var body struct {
+		Data TokenPair `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Data.AccessToken == "" || body.Data.RefreshToken == "" {
+		t.Errorf("incomplete token pair %+v", body.Data)
+	}
+
+	if len(db.users) != 1 {
+		t.Fatalf("provisioned %d users, want 1", len(db.users))
+	}
+
+	user := db.users[0]
+	if user.Email != "ada@example.com" || user.Username != "ada" || !user.IsActive {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	if len(db.identities) != 1 || db.identities[0].UserID != user.ID ||
+		db.identities[0].Issuer != server.Issuer() || db.identities[0].Subject != "42" {
+		t.Errorf("unexpected identities %+v", db.identities)
+	}
+
+	// the next login finds the linked user
+	if res := oidcLogin(t, app, server); res.Code != http.StatusOK {
+		t.Fatalf("second login returned %d: %s", res.Code, res.Body)
+	}
+
+	if len(db.users) != 1 || len(db.identities) != 1 {
+		t.Errorf("second login provisioned again: users %+v, identities %+v", db.users, db.identities)
+	}
+}
+
+func TestOIDCLinksVerifiedEmail(t *testing.T) {
+	app, server, db := newOIDCTestApp(t)
+	db.users = []*store.User{{ID: 7, Username: "ada", Email: "ada@example.com", IsActive: true}}
+
+	if res := oidcLogin(t, app, server); res.Code != http.StatusOK {
+		t.Fatalf("callback returned %d: %s", res.Code, res.Body)
+	}
+
+	if len(db.users) != 1 || len(db.identities) != 1 || db.identities[0].UserID != 7 {
+		t.Errorf("identity wasn't linked to the existing user: users %+v, identities %+v", db.users, db.identities)
+	}
+}
+
+func TestOIDCRefusesUnverifiedEmail(t *testing.T) {
+	tests := map[string][]*store.User{
+		"new email":      nil,
+		"existing email": {{ID: 7, Username: "ada", Email: "ada@example.com", IsActive: true}},
+	}
+
+	for name, existing := range tests {
+		t.Run(name, func(t *testing.T) {
+			app, server, db := newOIDCTestApp(t)
+			server.Identity.EmailVerified = false
+			db.users = existing
+
+			res := oidcLogin(t, app, server)
+			if res.Code != http.StatusBadRequest {
+				t.Fatalf("callback returned %d, want %d", res.Code, http.StatusBadRequest)
+			}
+
+			if len(db.users) != len(existing) || len(db.identities) != 0 {
+				t.Errorf("unverified email was provisioned or linked: users %+v, identities %+v", db.users, db.identities)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackChecksState(t *testing.T) {
+	app, _, db := newOIDCTestApp(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/authentication/oidc/callback?code=code&state=forged", nil)
+	req.AddCookie(&http.Cookie{Name: oidcFlowCookie, Value: "state.nonce.verifier"})
+
+	res := httptest.NewRecorder()
+	app.oidcCallbackHandler(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("callback returned %d, want %d", res.Code, http.StatusBadRequest)
+	}
+
+	if len(db.users) != 0 {
+		t.Errorf("forged state provisioned a user")
+	}
+}
+
+// the fakes embed the SQL stores without a database, methods the login flow doesn't use panic
+
+type oidcTestUsers struct {
+	*store.UserStore
+	db *oidcTestDB
+}
+
+func (s oidcTestUsers) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, user := range s.db.users {
+		if user.ID == id && user.IsActive {
+			return user, nil
+		}
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (s oidcTestUsers) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, user := range s.db.users {
+		if user.Email == email && user.IsActive {
+			return user, nil
+		}
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (s oidcTestUsers) CreateWithIdentity(ctx context.Context, user *store.User, identity *store.Identity) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, existing := range s.db.users {
+		switch {
+		case existing.Email == user.Email:
+			return store.ErrDuplicateEmail
+		case existing.Username == user.Username:
+			return store.ErrDuplicateUsername
+		}
+	}
+
+	user.ID = int64(len(s.db.users) + 1)
+	user.IsActive = true
+	s.db.users = append(s.db.users, user)
+
+	identity.UserID = user.ID
+	s.db.identities = append(s.db.identities, identity)
+
+	return nil
+}
+
+type oidcTestIdentities struct {
+	*store.IdentityStore
+	db *oidcTestDB
+}
+
+func (s oidcTestIdentities) GetUserID(ctx context.Context, issuer, subject string) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, identity := range s.db.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity.UserID, nil
+		}
+	}
+
+	return 0, store.ErrNotFound
+}
+
+func (s oidcTestIdentities) Link(ctx context.Context, identity *store.Identity) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, existing := range s.db.identities {
+		if existing.Issuer == identity.Issuer && existing.Subject == identity.Subject {
+			return store.ErrIdentityAlreadyLinked
+		}
+	}
+
+	s.db.identities = append(s.db.identities, identity)
+	return nil
+}
+
+type oidcTestMFA struct {
+	*store.MFAStore
+}
+
+func (oidcTestMFA) GetTOTP(context.Context, int64) (*store.TOTP, error) {
+	return nil, store.ErrNotFound
+}
+
+type oidcTestRefreshTokens struct {
+	*store.RefreshTokenStore
+}
+
+func (oidcTestRefreshTokens) Create(context.Context, *store.RefreshToken) error {
+	return nil
+}
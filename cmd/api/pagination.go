@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+var errCursorWithOffset = errors.New("cursor and offset can't be combined")
+
+// readFeedCursor verifies the cursor of the query and sets the position the page starts after.
+func (app *application) readFeedCursor(fq *store.PaginatedFeedQuery) error {
+	if fq.Cursor == "" {
+		return nil
+	}
+
+	if fq.Offset > 0 {
+		return errCursorWithOffset
+	}
+
+	var position store.FeedPosition
+	if err := app.cursors.Decode(fq.Cursor, &position); err != nil {
+		return err
+	}
+
+	fq.After = &position
+	return nil
+}
+
+// nextFeedCursor returns the cursor following the last item of a page, position gives the
+// key of the i-th item. A page shorter than the limit is the last one and gets no cursor.
+func (app *application) nextFeedCursor(fq store.PaginatedFeedQuery, count int, position func(i int) store.FeedPosition) (string, error) {
+	if count == 0 || count < fq.Limit {
+		return "", nil
+	}
+
+	return app.cursors.Encode(position(count - 1))
+}
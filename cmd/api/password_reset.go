@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/saikumaradapa/Connection-Sphere/internal/mailer"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Requests a password reset
+//	@Description	Emails a single-use password reset link. The response is the same whether the email is registered or not.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"Account email"
+//	@Success		202		{string}	string					"Reset email sent if the account exists"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	accepted := map[string]string{
+		"message": "if the account exists, a password reset email has been sent",
+	}
+
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			// same answer as for a registered email to avoid enumeration
+			if err := app.jsonResponse(w, http.StatusAccepted, accepted); err != nil {
+				app.internalServerError(w, r, err)
+			}
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	plainToken := uuid.New().String()
+
+	if err := app.store.Users.CreatePasswordReset(ctx, user.ID, hashToken(plainToken), app.config.mail.resetExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, plainToken),
+		ExpiresIn: app.config.mail.resetExp.String(),
+	}
+
+	// a failed send is only logged, answering differently would reveal the account exists
+	err = app.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending mail", "error", err, "user_id", user.ID)
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, accepted); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,min=3,max=75"`
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets the password
+//	@Description	Consumes a password reset token, sets the new password and logs the user out of every session
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		200		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/reset [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.ResetPassword(ctx, payload.Token, payload.Password)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidToken, store.ErrResetTokenExpired:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// access tokens died with the token generation bump, the refresh tokens have to follow
+	if err := app.store.RefreshTokens.RevokeAllForUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.invalidateUserCache(ctx, user.ID)
+	app.clearLoginFailures(ctx, user.Email)
+
+	if err := app.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "password reset",
+	}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
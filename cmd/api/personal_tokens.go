@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+const (
+	// personalTokenPrefix tells personal access tokens apart from JWTs in the Authorization header
+	personalTokenPrefix = "csp_"
+
+	scopeRead  = "read"  // safe methods (GET, HEAD, OPTIONS)
+	scopeWrite = "write" // every method, account security and admin routes excepted (see requireSession)
+)
+
+var errSessionRequired = errors.New("this route requires logging in, personal access tokens aren't accepted")
+
+type CreatePersonalTokenPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=read write"`
+	ExpiresInDays *int     `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+type PersonalTokenWithSecret struct {
+	*store.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// createPersonalTokenHandler godoc
+//
+//	@Summary		Creates a personal access token
+//	@Description	Creates a named, scoped and optionally expiring token for scripts and integrations. The token is shown only once.
+//	@Tags			tokens
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreatePersonalTokenPayload	true	"Token payload"
+//	@Success		201		{object}	PersonalTokenWithSecret
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Token name already used"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [post]
+func (app *application) createPersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	var payload CreatePersonalTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	plainToken := personalTokenPrefix + secret
+
+	token := &store.PersonalAccessToken{
+		UserID: user.ID,
+		Name:   payload.Name,
+		Token:  hashToken(plainToken),
+		Scopes: payload.Scopes,
+	}
+
+	if payload.ExpiresInDays != nil {
+		expiry := time.Now().AddDate(0, 0, *payload.ExpiresInDays)
+		token.Expiry = &expiry
+	}
+
+	if err := app.store.PersonalTokens.Create(r.Context(), token); err != nil {
+		switch err {
+		case store.ErrDuplicateTokenName:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, PersonalTokenWithSecret{
+		PersonalAccessToken: token,
+		Token:               plainToken,
+	}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// listPersonalTokensHandler godoc
+//
+//	@Summary		Lists personal access tokens
+//	@Description	Lists the personal access tokens of the authenticated user, without their secret
+//	@Tags			tokens
+//	@Produce		json
+//	@Success		200	{object}	[]store.PersonalAccessToken
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [get]
+func (app *application) listPersonalTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	tokens, err := app.store.PersonalTokens.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// revokePersonalTokenHandler godoc
+//
+//	@Summary		Revokes a personal access token
+//	@Description	Deletes one of the personal access tokens of the authenticated user
+//	@Tags			tokens
+//	@Produce		json
+//	@Param			tokenID	path	int	true	"Token ID"
+//	@Success		204		"Token revoked (no content returned)"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens/{tokenID} [delete]
+func (app *application) revokePersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.PersonalTokens.Delete(r.Context(), user.ID, tokenID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticatePersonalToken resolves the user behind a personal access token and
+// checks the token scopes allow the request method.
+func (app *application) authenticatePersonalToken(r *http.Request, plainToken string) (*store.User, error) {
+	ctx := r.Context()
+
+	token, err := app.store.PersonalTokens.GetByToken(ctx, hashToken(plainToken))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			return nil, store.ErrInvalidToken
+		default:
+			return nil, err
+		}
+	}
+
+	if token.Expiry != nil && time.Now().After(*token.Expiry) {
+		return nil, errors.New("personal access token has expired")
+	}
+
+	if !personalTokenAllows(token.Scopes, r.Method) {
+		return nil, errors.New("personal access token scopes don't allow this request")
+	}
+
+	if err := app.store.PersonalTokens.Touch(ctx, token.ID); err != nil {
+		app.logger.Warnw("failed to update token usage", "token_id", token.ID, "err", err)
+	}
+
+	return app.getUser(ctx, token.UserID)
+}
+
+// requireSession refuses requests authenticated with a personal access token, for the routes
+// that manage the account itself: a leaked token must not be able to take it over or to mint
+// tokens outliving its revocation. It runs after AuthTokenMiddleware.
+func (app *application) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := getClaimsFromCtx(r); err != nil {
+			app.forbiddenErrorResponse(w, r, errSessionRequired)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func personalTokenAllows(scopes []string, method string) bool {
+	for _, scope := range scopes {
+		switch scope {
+		case scopeWrite:
+			return true
+		case scopeRead:
+			if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/saikumaradapa/Connection-Sphere/internal/store"
@@ -19,12 +20,18 @@ type CreatePostPayload struct {
 	Title   string   `json:"title" validate:"required,max=100"`
 	Content string   `json:"content" validate:"required,max=1000"`
 	Tags    []string `json:"tags"`
+
+	QuoteOfID *int64 `json:"quote_of_id" validate:"omitempty,gte=1"` // makes the post a quote of another one
+
+	// Status defaults to published, scheduled posts need a publish_at in the future
+	Status    string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at" validate:"required_if=Status scheduled,excluded_unless=Status scheduled"`
 }
 
 // CreatePost godoc
 //
 //	@Summary		Creates a post
-//	@Description	Creates a post
+//	@Description	Creates a post, or a quote post embedding another one when quote_of_id is set. Drafts and scheduled posts stay private until published.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -53,20 +60,57 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if payload.PublishAt != nil && !payload.PublishAt.After(time.Now()) {
+		app.badRequestResponse(w, r, errPublishAtInPast)
+		return
+	}
+
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+		Title:     payload.Title,
+		Content:   payload.Content,
+		Tags:      payload.Tags,
+		UserID:    user.ID,
+		Status:    payload.Status,
+		PublishAt: payload.PublishAt,
 	}
 
 	ctx := r.Context()
 
+	if payload.QuoteOfID != nil {
+		quoted, err := app.store.Posts.GetByID(ctx, *payload.QuoteOfID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.badRequestResponse(w, r, errQuotedPostNotFound)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if quoted.Status != store.PostStatusPublished && quoted.UserID != user.ID {
+			app.badRequestResponse(w, r, errQuotedPostNotFound)
+			return
+		}
+
+		original, err := sharedOriginal(quoted)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		post.Kind = store.PostKindQuote
+		post.OriginalID = &original.ID
+		post.Original = original
+	}
+
 	if err := app.store.Posts.Create(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	app.pushToTimelines(ctx, post)
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -94,7 +138,8 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	// only the newest page is embedded, the rest is under /posts/{postID}/comments
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, defaultCommentsQuery())
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -102,6 +147,17 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	post.Comments = comments
 
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.attachReactions(r.Context(), []*store.Post{post}, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -172,6 +228,11 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if post.Kind == store.PostKindRepost {
+		app.badRequestResponse(w, r, errRepostNotEditable)
+		return
+	}
+
 	var payload UpdatePostPayload
 
 	if err := readJSON(w, r, &payload); err != nil {
@@ -192,7 +253,8 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Title = *payload.Title
 	}
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	// only the newest page is embedded, the rest is under /posts/{postID}/comments
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, defaultCommentsQuery())
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -232,6 +294,15 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// drafts and scheduled posts don't exist for anyone but their author
+		if post.Status != store.PostStatusPublished {
+			user, err := getUserFromCtx(r)
+			if err != nil || user.ID != post.UserID {
+				app.notFoundResponse(w, r, store.ErrNotFound)
+				return
+			}
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runPublisher publishes the scheduled posts once their publish_at is reached.
+func (app *application) runPublisher(ctx context.Context) {
+	ticker := time.NewTicker(app.config.posts.publishInterval)
+	defer ticker.Stop()
+
+	for {
+		app.publishDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) publishDue(ctx context.Context) {
+	published, err := app.store.Posts.PublishDue(ctx)
+	if err != nil {
+		app.logger.Errorw("publisher: failed to publish scheduled posts", "error", err)
+		return
+	}
+
+	for i := range published {
+		app.pushToTimelines(ctx, &published[i])
+	}
+
+	if len(published) > 0 {
+		app.logger.Infow("publisher: published scheduled posts", "posts", len(published))
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/saikumaradapa/Connection-Sphere/internal/ranking"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+// sortRanked orders the feed by relevance instead of creation time.
+const sortRanked = "ranked"
+
+var (
+	errCursorWithRanked = errors.New("the ranked feed is paged with offset, not cursor")
+	errDebugForbidden   = errors.New("debug mode is for admins")
+)
+
+// RankedPost is a feed item of the ranked feed, Score explains its rank in debug mode.
+type RankedPost struct {
+	store.PostWithMetadata
+	Score *ranking.Score `json:"score,omitempty"`
+}
+
+// rankedFeedHandler serves sort=ranked. The newest posts of the feed are the candidates,
+// ranked as a whole and paged with offset, the ranking moves with time so it has no cursor.
+func (app *application) rankedFeedHandler(w http.ResponseWriter, r *http.Request, viewer *store.User, fq store.PaginatedFeedQuery) {
+	if fq.Cursor != "" {
+		app.badRequestResponse(w, r, errCursorWithRanked)
+		return
+	}
+
+	debug := false
+	if d := r.URL.Query().Get("debug"); d != "" {
+		var err error
+		if debug, err = strconv.ParseBool(d); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	if debug {
+		allowed, err := app.hasPermission(ctx, viewer, store.PermissionUsersManage)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenErrorResponse(w, r, errDebugForbidden)
+			return
+		}
+	}
+
+	ranked, err := app.rankFeed(ctx, viewer.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if debug {
+		w.Header().Set("X-Ranking-Strategy", app.ranker.Strategy())
+	} else {
+		for i := range ranked {
+			ranked[i].Score = nil
+		}
+	}
+
+	if err := app.pageResponse(w, r, ranked, ""); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// rankFeed ranks the candidates of the feed for the viewer and returns the page at fq.Offset.
+func (app *application) rankFeed(ctx context.Context, viewerID int64, fq store.PaginatedFeedQuery) ([]RankedPost, error) {
+	cq := fq
+	cq.Sort = "desc"
+	cq.Offset = 0
+	cq.Limit = app.config.ranking.candidates
+
+	feed, err := app.getFeed(ctx, viewerID, cq)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.addViewerMetadata(ctx, feed, viewerID); err != nil {
+		return nil, err
+	}
+
+	since := app.ranker.Now().Add(-app.config.ranking.signalsWindow)
+	interactions, err := app.store.Interactions.GetByUser(ctx, viewerID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]ranking.Candidate, len(feed))
+	for i, p := range feed {
+		createdAt, err := time.Parse(time.RFC3339Nano, p.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates[i] = ranking.Candidate{
+			PostID:    p.ID,
+			AuthorID:  p.UserID,
+			CreatedAt: createdAt,
+			Comments:  p.CommentCount,
+			Tags:      p.Tags,
+		}
+		if p.Reactions != nil {
+			candidates[i].Reactions = p.Reactions.Total
+		}
+	}
+
+	viewer := ranking.NewViewer(viewerID, interactions.ByAuthor, interactions.ByTag)
+	ranked := app.ranker.Rank(candidates, viewer)
+
+	start := min(fq.Offset, len(ranked))
+	end := min(fq.Offset+fq.Limit, len(ranked))
+
+	page := make([]RankedPost, 0, end-start)
+	for _, rk := range ranked[start:end] {
+		page = append(page, RankedPost{PostWithMetadata: feed[rk.Index], Score: &rk.Score})
+	}
+
+	return page, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+// ReactPayload types match the check constraint of post_reactions.
+type ReactPayload struct {
+	Type string `json:"type" validate:"required,oneof=like love laugh wow sad angry"`
+}
+
+// reactToPostHandler godoc
+//
+//	@Summary		Reacts to a post
+//	@Description	Sets the reaction of the authenticated user on a post, replacing their previous one. Repeating the call changes nothing.
+//	@Tags			reactions
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		ReactPayload	true	"like, love, laugh, wow, sad or angry"
+//	@Success		200		{object}	store.ReactionSummary
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [put]
+func (app *application) reactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	var payload ReactPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Reactions.React(ctx, post.ID, user.ID, payload.Type); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.reactionsResponse(w, r, post.ID, user.ID)
+}
+
+// unreactToPostHandler godoc
+//
+//	@Summary		Removes a reaction
+//	@Description	Removes the reaction of the authenticated user from a post, if any
+//	@Tags			reactions
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.ReactionSummary
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [delete]
+func (app *application) unreactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Reactions.Unreact(r.Context(), post.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.reactionsResponse(w, r, post.ID, user.ID)
+}
+
+// reactionsResponse writes the updated summary, so clients don't have to refetch the post.
+func (app *application) reactionsResponse(w http.ResponseWriter, r *http.Request, postID, viewerID int64) {
+	summaries, err := app.store.Reactions.GetSummaries(r.Context(), []int64{postID}, viewerID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, summaries[postID]); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// attachReactions fills the reaction summaries of the posts for the viewer in one query.
+func (app *application) attachReactions(ctx context.Context, posts []*store.Post, viewerID int64) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	summaries, err := app.store.Reactions.GetSummaries(ctx, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		p.Reactions = summaries[p.ID]
+	}
+
+	return nil
+}
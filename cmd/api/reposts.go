@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+var (
+	errQuotedPostNotFound = errors.New("the quoted post doesn't exist")
+	errRepostNotEditable  = errors.New("reposts have no content to edit")
+	errPostNotPublished   = errors.New("only published posts can be shared")
+)
+
+// repostHandler godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Boosts a post to the followers of the authenticated user. Reposting a repost boosts its original.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		201		{object}	store.Post
+//	@Failure		400		{object}	error	"Original post deleted"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already reposted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [post]
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	original, err := sharedOriginal(post)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	repost, err := app.store.Posts.Repost(r.Context(), user.ID, original.ID)
+	if err != nil {
+		switch err {
+		case store.ErrAlreadyReposted:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.pushToTimelines(r.Context(), repost)
+
+	if err := app.jsonResponse(w, http.StatusCreated, repost); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// deleteRepostHandler godoc
+//
+//	@Summary		Undoes a repost
+//	@Description	Removes the repost of a post by the authenticated user
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path	int	true	"Post ID"
+//	@Success		204		"Repost removed"
+//	@Failure		400		{object}	error	"Original post deleted"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error	"Post not found or not reposted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [delete]
+func (app *application) deleteRepostHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	original, err := sharedOriginal(post)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Posts.DeleteRepost(r.Context(), user.ID, original.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sharedOriginal is the post that reposts and quotes of the given post reference. Reposts have
+// no content of their own, so sharing one shares its original instead.
+func sharedOriginal(post *store.Post) (*store.Post, error) {
+	if post.Status != store.PostStatusPublished {
+		return nil, errPostNotPublished
+	}
+
+	if post.Kind != store.PostKindRepost {
+		return post, nil
+	}
+
+	if post.Original == nil {
+		return nil, store.ErrOriginalDeleted
+	}
+
+	return post.Original, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saikumaradapa/Connection-Sphere/internal/diff"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+var (
+	errInvalidVersion   = errors.New("invalid version")
+	errVersionIsCurrent = errors.New("this version is the current one")
+	errPostEditConflict = errors.New("the post was edited in the meantime, try again")
+)
+
+// listRevisionsHandler godoc
+//
+//	@Summary		Lists the revisions of a post
+//	@Description	Lists the previous versions of a post, newest first. Only the author and moderators can see them.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	[]store.PostRevision
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions [get]
+func (app *application) listRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	revisions, err := app.store.Posts.GetRevisions(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RevisionDiff lists the line edits turning one version of a post into another.
+type RevisionDiff struct {
+	From    int         `json:"from"`
+	To      int         `json:"to"`
+	Title   []diff.Edit `json:"title"`
+	Content []diff.Edit `json:"content"`
+}
+
+// diffRevisionsHandler godoc
+//
+//	@Summary		Compares two versions of a post
+//	@Description	Diffs the title and content of two versions, by default the current one against the one before it
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			from	query		int	false	"Older version, to - 1 by default"
+//	@Param			to		query		int	false	"Newer version, the current one by default"
+//	@Success		200		{object}	RevisionDiff
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/diff [get]
+func (app *application) diffRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	to, err := versionParam(r.URL.Query().Get("to"), post.Version)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	from, err := versionParam(r.URL.Query().Get("from"), to-1)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	older, err := app.postVersion(ctx, post, from)
+	if err != nil {
+		app.revisionErrorResponse(w, r, err)
+		return
+	}
+
+	newer, err := app.postVersion(ctx, post, to)
+	if err != nil {
+		app.revisionErrorResponse(w, r, err)
+		return
+	}
+
+	d := RevisionDiff{
+		From:    from,
+		To:      to,
+		Title:   diff.Lines(older.Title, newer.Title),
+		Content: diff.Lines(older.Content, newer.Content),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, d); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// restoreRevisionHandler godoc
+//
+//	@Summary		Restores a revision
+//	@Description	Makes a previous version the content of the post again, the replaced text becomes a revision too
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Version to restore"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Edited in the meantime"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version}/restore [post]
+func (app *application) restoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := getPostFromCtx(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	version, err := versionParam(chi.URLParam(r, "version"), 0)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if version == post.Version {
+		app.badRequestResponse(w, r, errVersionIsCurrent)
+		return
+	}
+
+	ctx := r.Context()
+
+	revision, err := app.store.Posts.GetRevision(ctx, post.ID, version)
+	if err != nil {
+		app.revisionErrorResponse(w, r, err)
+		return
+	}
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+
+	if err := app.store.Posts.Update(ctx, post); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.conflictResponse(w, r, errPostEditConflict)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("post revision restored", "post_id", post.ID, "version", version, "by", adminID(r))
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// postVersion returns the text of the post at the given version, the current one included.
+func (app *application) postVersion(ctx context.Context, post *store.Post, version int) (*store.PostRevision, error) {
+	if version == post.Version {
+		return &store.PostRevision{
+			PostID:  post.ID,
+			Version: post.Version,
+			Title:   post.Title,
+			Content: post.Content,
+			Tags:    post.Tags,
+		}, nil
+	}
+
+	return app.store.Posts.GetRevision(ctx, post.ID, version)
+}
+
+// versionParam parses a version, falling back to def when the parameter is empty.
+func versionParam(param string, def int) (int, error) {
+	if param == "" {
+		return def, nil
+	}
+
+	version, err := strconv.Atoi(param)
+	if err != nil || version < 0 {
+		return 0, errInvalidVersion
+	}
+
+	return version, nil
+}
+
+func (app *application) revisionErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case store.ErrNotFound:
+		app.notFoundResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
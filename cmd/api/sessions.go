@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type claimsKey string
+
+const claimsCtx claimsKey = "claims"
+
+var errTokenRevoked = errors.New("token has been revoked")
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the access token used for this request and the refresh tokens of the same login
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		204	"Logged out (no content returned)"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getClaimsFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.revokeAccessToken(ctx, claims); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if familyID, _ := claims["fid"].(string); familyID != "" {
+		if err := app.store.RefreshTokens.RevokeFamily(ctx, familyID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logoutAllHandler godoc
+//
+//	@Summary		Logs out everywhere
+//	@Description	Revokes every access and refresh token of the authenticated user
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		204	"Logged out of every session (no content returned)"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout/all [post]
+func (app *application) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.revokeUserSessions(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAccessToken puts the token on the denylist until it expires.
+func (app *application) revokeAccessToken(ctx context.Context, claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return errors.New("token has no expiration")
+	}
+
+	if !app.config.redisCfg.enabled {
+		return app.store.RevokedTokens.Revoke(ctx, jti, exp.Time)
+	}
+
+	return app.cacheStore.Tokens.Revoke(ctx, jti, exp.Time)
+}
+
+// revokeUserSessions ends every session of the user: bumping the token generation invalidates
+// the access tokens and the refresh tokens are revoked so no new ones can be minted.
+func (app *application) revokeUserSessions(ctx context.Context, userID int64) error {
+	if err := app.store.Users.IncrementTokenGeneration(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := app.store.RefreshTokens.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	// the cached user still carries the old generation
+	app.invalidateUserCache(ctx, userID)
+
+	return nil
+}
+
+func (app *application) invalidateUserCache(ctx context.Context, userID int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStore.Users.Delete(ctx, userID); err != nil {
+		app.logger.Warnw("failed to invalidate cache", "id", userID, "err", err)
+	}
+}
+
+func getClaimsFromCtx(r *http.Request) (jwt.MapClaims, error) {
+	claims, ok := r.Context().Value(claimsCtx).(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("token claims missing in context")
+	}
+	return claims, nil
+}
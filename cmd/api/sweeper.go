@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runSweeper periodically purges expired invitations, removes users that never activated
+// their account within the grace period, so their email and username can be used again,
+// and deletes the refresh and revoked tokens and the failed login attempts that have expired.
+func (app *application) runSweeper(ctx context.Context) {
+	ticker := time.NewTicker(app.config.activation.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		app.sweep(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep runs every cleanup, a failing one doesn't hold back the others.
+func (app *application) sweep(ctx context.Context) {
+	invitations, err := app.store.Users.DeleteExpiredInvitations(ctx)
+	if err != nil {
+		app.logger.Errorw("sweeper: failed to delete expired invitations", "error", err)
+	}
+
+	users, err := app.store.Users.DeleteUnactivated(ctx, app.config.activation.gracePeriod)
+	if err != nil {
+		app.logger.Errorw("sweeper: failed to delete unactivated users", "error", err)
+	}
+
+	revokedTokens, err := app.store.RevokedTokens.DeleteExpired(ctx)
+	if err != nil {
+		app.logger.Errorw("sweeper: failed to delete expired revoked tokens", "error", err)
+	}
+
+	refreshTokens, err := app.store.RefreshTokens.DeleteExpired(ctx)
+	if err != nil {
+		app.logger.Errorw("sweeper: failed to delete expired refresh tokens", "error", err)
+	}
+
+	loginAttempts, err := app.store.LoginAttempts.DeleteExpired(ctx, app.lockoutPolicy())
+	if err != nil {
+		app.logger.Errorw("sweeper: failed to delete expired login attempts", "error", err)
+	}
+
+	if invitations > 0 || users > 0 || revokedTokens > 0 || refreshTokens > 0 || loginAttempts > 0 {
+		app.logger.Infow("sweeper: cleaned up",
+			"invitations", invitations,
+			"users", users,
+			"revoked_tokens", revokedTokens,
+			"refresh_tokens", refreshTokens,
+			"login_attempts", loginAttempts,
+		)
+	}
+}
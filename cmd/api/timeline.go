@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store/cache"
+)
+
+// Feeds are materialized in Redis: published posts are pushed to the timeline of their author
+// and followers (fan-out on write). Authors with more followers than the celebrity threshold
+// aren't pushed, readers following them merge their posts from SQL (fan-out on read).
+// Without Redis, or for filtered and offset pages, the feed is read from SQL.
+
+func (app *application) timelinesEnabled() bool {
+	return app.config.redisCfg.enabled
+}
+
+// servedByTimeline tells whether the page can be read from the materialized timeline, which
+// only knows the unfiltered feed, newest first.
+func servedByTimeline(fq store.PaginatedFeedQuery) bool {
+	return fq.Offset == 0 &&
+		fq.Sort == "desc" &&
+		fq.Search == "" &&
+		len(fq.Tags) == 0 &&
+		fq.Since == "" &&
+		fq.Until == ""
+}
+
+// pushToTimelines fans a published post out. Failures are logged only: the post exists
+// either way, and the timelines missing it are right again once rebuilt.
+func (app *application) pushToTimelines(ctx context.Context, post *store.Post) {
+	if !app.timelinesEnabled() || post.Status != store.PostStatusPublished {
+		return
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, post.CreatedAt)
+	if err != nil {
+		app.logger.Errorw("timeline: invalid post creation time", "post_id", post.ID, "error", err)
+		return
+	}
+
+	threshold := app.config.posts.celebrityThreshold
+
+	followers, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID, threshold+1)
+	if err != nil {
+		app.logger.Errorw("timeline: failed to load followers", "user_id", post.UserID, "error", err)
+		return
+	}
+
+	if len(followers) > threshold {
+		if err := app.cacheStore.Timelines.MarkCelebrity(ctx, post.UserID); err != nil {
+			app.logger.Errorw("timeline: failed to mark celebrity", "user_id", post.UserID, "error", err)
+			return
+		}
+		followers = nil
+	}
+
+	entry := store.TimelineEntry{PostID: post.ID, CreatedAt: createdAt}
+	if err := app.cacheStore.Timelines.Push(ctx, append(followers, post.UserID), entry); err != nil {
+		app.logger.Errorw("timeline: failed to push post", "post_id", post.ID, "error", err)
+	}
+}
+
+// dropTimeline discards the timeline of the user after a follow or unfollow, it is built
+// again with the right authors on the next read.
+func (app *application) dropTimeline(ctx context.Context, userID int64) {
+	if !app.timelinesEnabled() {
+		return
+	}
+
+	if err := app.cacheStore.Timelines.Delete(ctx, userID); err != nil {
+		app.logger.Errorw("timeline: failed to drop timeline", "user_id", userID, "error", err)
+	}
+}
+
+// readTimeline returns a page of the feed from the materialized timeline. It returns false
+// when the page has to come from SQL instead, because it is older than what timelines keep.
+// Posts deleted or unpublished since they were pushed are dropped from the timeline and the
+// page is completed with the entries after them, so that only the last page is short.
+func (app *application) readTimeline(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, bool, error) {
+	feed := make([]store.PostWithMetadata, 0, fq.Limit)
+
+	for {
+		entries, ok, err := app.timelineEntries(ctx, userID, fq)
+		if err != nil || !ok {
+			return nil, ok, err
+		}
+
+		ids := make([]int64, len(entries))
+		for i, e := range entries {
+			ids[i] = e.PostID
+		}
+
+		hydrated, err := app.store.Posts.GetFeedByIDs(ctx, ids)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if len(hydrated) < len(ids) {
+			if err := app.cacheStore.Timelines.Remove(ctx, userID, missingPosts(ids, hydrated)); err != nil {
+				return nil, false, err
+			}
+		}
+
+		feed = append(feed, hydrated[:min(len(hydrated), fq.Limit-len(feed))]...)
+
+		if len(feed) == fq.Limit || len(entries) < fq.Limit {
+			return feed, true, nil
+		}
+
+		// the next read starts after the last entry, whether its post was found or not
+		last := entries[len(entries)-1]
+		fq.After = &store.FeedPosition{CreatedAt: last.CreatedAt.Format(time.RFC3339Nano), ID: last.PostID}
+	}
+}
+
+func (app *application) timelineEntries(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) ([]store.TimelineEntry, bool, error) {
+	page, err := app.cacheStore.Timelines.Read(ctx, userID, fq.After, fq.Limit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !page.Built {
+		entries, err := app.store.Posts.GetTimeline(ctx, userID, cache.TimelineMaxLength)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if err := app.cacheStore.Timelines.Build(ctx, userID, entries); err != nil {
+			return nil, false, err
+		}
+
+		if page, err = app.cacheStore.Timelines.Read(ctx, userID, fq.After, fq.Limit); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if page.Truncated && len(page.Entries) < fq.Limit {
+		return nil, false, nil
+	}
+
+	following, err := app.store.Followers.GetFollowingIDs(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	celebrities, err := app.cacheStore.Timelines.Celebrities(ctx, following)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(celebrities) == 0 {
+		return page.Entries, true, nil
+	}
+
+	pulled, err := app.store.Posts.GetTimelineByAuthors(ctx, celebrities, fq.After, fq.Limit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return mergeTimelines(page.Entries, pulled, fq.Limit), true, nil
+}
+
+// mergeTimelines merges two timelines sorted newest first, without duplicates.
+func mergeTimelines(a, b []store.TimelineEntry, limit int) []store.TimelineEntry {
+	merged := make([]store.TimelineEntry, 0, min(len(a)+len(b), limit))
+	seen := make(map[int64]bool, len(a)+len(b))
+
+	for len(merged) < limit && (len(a) > 0 || len(b) > 0) {
+		var next store.TimelineEntry
+		if len(b) == 0 || (len(a) > 0 && newerEntry(a[0], b[0])) {
+			next, a = a[0], a[1:]
+		} else {
+			next, b = b[0], b[1:]
+		}
+
+		if !seen[next.PostID] {
+			seen[next.PostID] = true
+			merged = append(merged, next)
+		}
+	}
+
+	return merged
+}
+
+func newerEntry(a, b store.TimelineEntry) bool {
+	if a.CreatedAt.Equal(b.CreatedAt) {
+		return a.PostID > b.PostID
+	}
+
+	return a.CreatedAt.After(b.CreatedAt)
+}
+
+func missingPosts(ids []int64, feed []store.PostWithMetadata) []int64 {
+	found := make(map[int64]bool, len(feed))
+	for _, p := range feed {
+		found[p.ID] = true
+	}
+
+	missing := []int64{}
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	return missing
+}
@@ -15,12 +15,12 @@ const userCtx userKey = "user"
 // GetUser godoc
 //
 //	@Summary		Fetches a user profile
-//	@Description	Fetches a user profile by ID
+//	@Description	Fetches the public profile of a user by ID, with follower, following and post counts
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int	true	"userID"
-//	@Success		200		{object}	store.User
+//	@Success		200		{object}	store.PublicProfile
 //	@Failure		400		{object}	error
 //	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
@@ -47,7 +47,13 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	stats, err := app.store.Users.GetStats(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user.PublicProfile(stats)); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -95,6 +101,8 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	app.dropTimeline(ctx, follower.ID)
+
 	app.jsonResponse(w, http.StatusNoContent, nil)
 }
 
@@ -140,6 +148,8 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.dropTimeline(ctx, follower.ID)
+
 	app.jsonResponse(w, http.StatusNoContent, nil)
 }
 
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    scope varchar(20) NOT NULL, -- what the identifier is: an account email or a client ip
+    identifier varchar(255) NOT NULL,
+    failed_count int NOT NULL DEFAULT 0,
+    locked_until timestamp(0) with time zone,
+    last_failed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (scope, identifier)
+);
+
+-- the sweeper purges keys that stopped failing
+CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failed_at ON login_attempts (last_failed_at);
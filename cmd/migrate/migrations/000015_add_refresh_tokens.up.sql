@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id bigserial PRIMARY KEY,
+    token bytea NOT NULL UNIQUE, -- sha256 of the token handed to the client
+    user_id bigint NOT NULL,
+    family_id uuid NOT NULL, -- every rotation of one login shares the family
+    expiry timestamp(0) with time zone NOT NULL,
+    used_at timestamp(0) with time zone,
+    revoked_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+
+-- the sweeper purges expired families
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expiry ON refresh_tokens (expiry);
@@ -0,0 +1,14 @@
+-- bumping the generation invalidates every access token issued before (log out everywhere)
+ALTER TABLE
+    users
+ADD
+    COLUMN token_generation INT NOT NULL DEFAULT 0;
+
+-- denylist of single access tokens, used when Redis is disabled
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti uuid PRIMARY KEY,
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+-- the sweeper purges tokens past their expiry
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expiry ON revoked_tokens (expiry);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id bigint PRIMARY KEY,
+    secret text NOT NULL,
+    enabled boolean NOT NULL DEFAULT false, -- true once the first code was confirmed
+    last_used_step bigint NOT NULL DEFAULT 0, -- refuses replaying a code within its time step
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    code bytea NOT NULL, -- sha256 of the code shown to the user once
+    used_at timestamp(0) with time zone,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
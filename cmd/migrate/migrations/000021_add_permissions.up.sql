@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY,
+    name varchar(100) NOT NULL UNIQUE, -- resource:action[:scope], e.g. posts:delete:any
+    description text
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id bigint NOT NULL,
+    permission_id bigint NOT NULL,
+
+    PRIMARY KEY (role_id, permission_id),
+    FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE CASCADE,
+    FOREIGN KEY (permission_id) REFERENCES permissions (id) ON DELETE CASCADE
+);
+
+INSERT INTO
+    permissions (name, description)
+VALUES
+    ('posts:update:any', 'Update posts of other users'),
+    ('posts:delete:any', 'Delete posts of other users'),
+    ('users:ban', 'Suspend and ban users')
+ON CONFLICT (name) DO NOTHING;
+
+-- Same capabilities the role levels used to grant: moderators update any post,
+-- admins update and delete any post and ban users.
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    r.id,
+    p.id
+FROM
+    roles r
+    JOIN permissions p ON (r.name, p.name) IN (
+        ('moderator', 'posts:update:any'),
+        ('admin', 'posts:update:any'),
+        ('admin', 'posts:delete:any'),
+        ('admin', 'users:ban')
+    )
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,10 @@
+DELETE FROM permissions WHERE name = 'users:manage';
+
+ALTER TABLE
+    IF EXISTS users
+DROP
+    COLUMN IF EXISTS banned_at,
+DROP
+    COLUMN IF EXISTS suspension_reason,
+DROP
+    COLUMN IF EXISTS suspended_until;
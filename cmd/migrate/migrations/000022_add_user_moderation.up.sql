@@ -0,0 +1,27 @@
+ALTER TABLE
+    IF EXISTS users
+ADD
+    COLUMN IF NOT EXISTS suspended_until timestamp(0) with time zone, -- suspension lifts by itself once passed
+ADD
+    COLUMN IF NOT EXISTS suspension_reason text,
+ADD
+    COLUMN IF NOT EXISTS banned_at timestamp(0) with time zone;
+
+INSERT INTO
+    permissions (name, description)
+VALUES
+    ('users:manage', 'List users, change their role and reactivate them')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    r.id,
+    p.id
+FROM
+    roles r,
+    permissions p
+WHERE
+    r.name = 'admin'
+    AND p.name = 'users:manage'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,15 @@
+ALTER TABLE
+    comments
+DROP
+    CONSTRAINT IF EXISTS fk_comments_post,
+DROP
+    CONSTRAINT IF EXISTS fk_comments_user;
+
+ALTER TABLE
+    posts
+DROP
+    CONSTRAINT IF EXISTS fk_user,
+ADD
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id);
+
+DROP TABLE IF EXISTS email_changes;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS email_changes (
+    token bytea PRIMARY KEY, -- sha256 of the token sent to the new address
+    user_id bigint NOT NULL,
+    new_email citext NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Deleting an account deletes its posts and comments, and the comments left on its posts.
+DELETE FROM comments
+WHERE
+    user_id NOT IN (SELECT id FROM users)
+    OR post_id NOT IN (SELECT id FROM posts);
+
+ALTER TABLE
+    posts
+DROP
+    CONSTRAINT IF EXISTS fk_user,
+ADD
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE
+    comments
+ADD
+    CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+ADD
+    CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE;
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_followers_follower_id;
+
+ALTER TABLE
+    IF EXISTS users
+DROP
+    COLUMN IF EXISTS location,
+DROP
+    COLUMN IF EXISTS website,
+DROP
+    COLUMN IF EXISTS avatar_url,
+DROP
+    COLUMN IF EXISTS bio,
+DROP
+    COLUMN IF EXISTS display_name;
@@ -0,0 +1,15 @@
+ALTER TABLE
+    IF EXISTS users
+ADD
+    COLUMN IF NOT EXISTS display_name varchar(100) NOT NULL DEFAULT '',
+ADD
+    COLUMN IF NOT EXISTS bio varchar(500) NOT NULL DEFAULT '',
+ADD
+    COLUMN IF NOT EXISTS avatar_url varchar(500) NOT NULL DEFAULT '',
+ADD
+    COLUMN IF NOT EXISTS website varchar(500) NOT NULL DEFAULT '',
+ADD
+    COLUMN IF NOT EXISTS location varchar(100) NOT NULL DEFAULT '';
+
+-- followers are looked up by user_id through the primary key, following counts need the other side
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id ON followers (follower_id);
@@ -0,0 +1,8 @@
+DELETE FROM permissions WHERE name IN ('comments:update:any', 'comments:delete:any');
+
+DROP INDEX IF EXISTS idx_comments_post_id_created_at;
+
+ALTER TABLE
+    IF EXISTS comments
+DROP
+    COLUMN IF EXISTS edited_at;
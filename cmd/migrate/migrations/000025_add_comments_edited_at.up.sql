@@ -0,0 +1,28 @@
+ALTER TABLE
+    IF EXISTS comments
+ADD
+    COLUMN IF NOT EXISTS edited_at timestamp(0) with time zone; -- NULL until the content changes
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_id_created_at ON comments (post_id, created_at);
+
+INSERT INTO
+    permissions (name, description)
+VALUES
+    ('comments:update:any', 'Update comments of other users'),
+    ('comments:delete:any', 'Delete comments of other users')
+ON CONFLICT (name) DO NOTHING;
+
+-- mirrors the post permissions
+INSERT INTO
+    role_permissions (role_id, permission_id)
+SELECT
+    r.id,
+    p.id
+FROM
+    roles r
+    JOIN permissions p ON (r.name, p.name) IN (
+        ('moderator', 'comments:update:any'),
+        ('admin', 'comments:update:any'),
+        ('admin', 'comments:delete:any')
+    )
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_comments_parent_id;
+
+-- replies would show up as top-level comments
+DELETE FROM comments WHERE parent_id IS NOT NULL;
+
+ALTER TABLE
+    IF EXISTS comments
+DROP
+    COLUMN IF EXISTS depth,
+DROP
+    COLUMN IF EXISTS parent_id;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS bookmarks (
+    user_id bigint NOT NULL,
+    post_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, post_id), -- composite key, a post is saved once per user
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE -- deleting a post removes its bookmarks
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_post_id ON bookmarks (post_id);
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_posts_user_id_repost;
+
+DROP INDEX IF EXISTS idx_posts_original_id;
+
+-- reposts have no content of their own
+DELETE FROM posts WHERE kind = 'repost';
+
+ALTER TABLE
+    IF EXISTS posts
+DROP
+    COLUMN IF EXISTS original_id,
+DROP
+    COLUMN IF EXISTS kind;
@@ -0,0 +1,11 @@
+ALTER TABLE
+    IF EXISTS posts
+ADD
+    COLUMN IF NOT EXISTS kind varchar(10) NOT NULL DEFAULT 'post' CHECK (kind IN ('post', 'repost', 'quote')),
+ADD
+    COLUMN IF NOT EXISTS original_id bigint REFERENCES posts (id) ON DELETE SET NULL; -- NULL once the original is deleted, the kind keeps the tombstone
+
+CREATE INDEX IF NOT EXISTS idx_posts_original_id ON posts (original_id);
+
+-- a post is reposted once per user, quotes are regular posts and have no limit
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_id_repost ON posts (user_id, original_id) WHERE kind = 'repost';
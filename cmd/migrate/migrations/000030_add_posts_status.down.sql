@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_posts_user_id_status;
+
+DROP INDEX IF EXISTS idx_posts_publish_at;
+
+-- unpublished posts would become public
+DELETE FROM posts WHERE status <> 'published';
+
+ALTER TABLE
+    IF EXISTS posts
+DROP
+    COLUMN IF EXISTS publish_at,
+DROP
+    COLUMN IF EXISTS status;
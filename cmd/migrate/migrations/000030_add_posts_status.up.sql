@@ -0,0 +1,11 @@
+ALTER TABLE
+    IF EXISTS posts
+ADD
+    COLUMN IF NOT EXISTS status varchar(10) NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'scheduled', 'published')),
+ADD
+    COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone; -- set for scheduled posts, kept once published
+
+-- the scheduler only looks at the posts waiting for their publication
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts (publish_at) WHERE status = 'scheduled';
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_status ON posts (user_id, status);
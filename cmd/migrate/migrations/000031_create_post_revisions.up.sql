@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id bigint NOT NULL,
+    version int NOT NULL, -- the version of the post this text belonged to
+    title text NOT NULL,
+    content text NOT NULL,
+    tags varchar(100) [],
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(), -- when the version was replaced
+
+    PRIMARY KEY (post_id, version),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+-- feed ranking reads the recent comments of a user
+CREATE INDEX IF NOT EXISTS idx_comments_user_id_created_at ON comments (user_id, created_at);
@@ -0,0 +1,2 @@
+-- explore and trending tags read the recent published posts of everyone
+CREATE INDEX IF NOT EXISTS idx_posts_created_at_published ON posts (created_at DESC, id DESC) WHERE status = 'published';
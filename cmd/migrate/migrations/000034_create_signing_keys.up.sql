@@ -0,0 +1,10 @@
+-- asymmetric JWT signing keys, shared by every instance and kept across restarts
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id varchar(32) PRIMARY KEY, -- the kid header of the tokens it signs
+    algorithm varchar(10) NOT NULL,
+    private_key bytea NOT NULL, -- PKCS #8, encrypted with JWT_KEY_SECRET
+    created_at timestamp with time zone NOT NULL,
+    retired_at timestamp with time zone -- NULL while the key is signing
+);
+
+CREATE INDEX IF NOT EXISTS idx_signing_keys_algorithm_created_at ON signing_keys (algorithm, created_at);
@@ -2,6 +2,9 @@ package auth
 
 import "github.com/golang-jwt/jwt/v5"
 
+// AlgorithmHS256 signs tokens with a secret shared by every instance.
+const AlgorithmHS256 = "HS256"
+
 type JWTAuthenticator struct {
 	secretKey string
 	aud       string
@@ -0,0 +1,437 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+
+	rsaKeyBits = 2048
+
+	// keyReloadInterval bounds how long a key rotated by another instance goes unnoticed,
+	// keyReloadMinInterval how often tokens with an unknown kid can trigger a reload.
+	keyReloadInterval    = time.Minute
+	keyReloadMinInterval = 5 * time.Second
+
+	keyStoreTimeout = 5 * time.Second
+)
+
+var errNoSigningKey = errors.New("no signing key")
+
+// StoredKey is a signing key as persisted, PrivateKey is PKCS #8 encrypted with AES-GCM.
+type StoredKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey []byte
+	CreatedAt  time.Time
+	RetiredAt  *time.Time
+}
+
+// KeyStore persists signing keys, so that every instance signs and verifies with the same
+// keys and restarts don't invalidate the tokens already issued.
+type KeyStore interface {
+	// Keys returns the signing key of the algorithm and the keys retired after retiredSince, newest first.
+	Keys(ctx context.Context, algorithm string, retiredSince time.Time) ([]StoredKey, error)
+	// Rotate makes key the signing key unless another one was created after dueBefore, and
+	// deletes the keys retired before retiredBefore.
+	Rotate(ctx context.Context, key StoredKey, dueBefore, retiredBefore time.Time) error
+}
+
+// KeySetAuthenticator signs tokens with asymmetric keys identified by the kid header.
+// The signing key rotates every rotation interval, retired keys keep verifying the
+// tokens they signed for the retention period (the longest token lifetime).
+// Public keys are published as a JWKS so other services can verify tokens without a shared secret.
+// Keys live in a KeyStore, instances reload them periodically and on tokens with an unknown kid.
+type KeySetAuthenticator struct {
+	mu        sync.RWMutex
+	keys      []*signingKey // newest (the one signing) first
+	loadedAt  time.Time
+	store     KeyStore
+	aead      cipher.AEAD // encrypts the stored private keys
+	method    jwt.SigningMethod
+	rotation  time.Duration
+	retention time.Duration
+	aud       string
+	iss       string
+}
+
+type signingKey struct {
+	id        string
+	signer    crypto.Signer
+	createdAt time.Time
+	retiredAt time.Time // zero while the key is signing
+}
+
+// NewKeySetAuthenticator loads the keys of the algorithm from the store, creating the first
+// one if needed. secret encrypts the private keys at rest.
+func NewKeySetAuthenticator(algorithm string, rotation, retention time.Duration, audience, issuer string, store KeyStore, secret string) (*KeySetAuthenticator, error) {
+	var method jwt.SigningMethod
+	switch algorithm {
+	case AlgorithmRS256:
+		method = jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	block, err := aes.NewCipher(deriveKey(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &KeySetAuthenticator{
+		store:     store,
+		aead:      aead,
+		method:    method,
+		rotation:  rotation,
+		retention: retention,
+		aud:       audience,
+		iss:       issuer,
+	}
+
+	if _, err := a.signingKey(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *KeySetAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	key, err := a.signingKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(a.method, claims)
+	token.Header["kid"] = key.id
+
+	tokenString, err := token.SignedString(key.signer)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (a *KeySetAuthenticator) ValidateToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		key := a.verificationKey(kid, time.Now())
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+
+		return key.signer.Public(), nil
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(a.aud),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods([]string{a.method.Alg()}),
+	)
+}
+
+// JWKS returns the public keys that currently verify tokens.
+func (a *KeySetAuthenticator) JWKS() JWKSet {
+	// rotating here keeps the published set current when nothing is being signed,
+	// if it fails the previous keys are still valid and get published
+	_, _ = a.signingKey(time.Now())
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(a.keys))}
+	for _, key := range a.keys {
+		set.Keys = append(set.Keys, publicJWK(key, a.method.Alg()))
+	}
+
+	return set
+}
+
+// signingKey returns the current key, reloading the keys or rotating first when due.
+func (a *KeySetAuthenticator) signingKey(now time.Time) (*signingKey, error) {
+	a.mu.RLock()
+	current, fresh := a.current(now), now.Sub(a.loadedAt) < keyReloadInterval
+	a.mu.RUnlock()
+
+	if current != nil && fresh {
+		return current, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// another request may have reloaded while we waited for the lock
+	if now.Sub(a.loadedAt) >= keyReloadInterval {
+		if err := a.reload(now); err != nil && a.current(now) == nil {
+			return nil, err
+		}
+	}
+
+	if current := a.current(now); current != nil {
+		return current, nil
+	}
+
+	if err := a.rotate(now); err != nil {
+		return nil, err
+	}
+
+	if current := a.current(now); current != nil {
+		return current, nil
+	}
+
+	return nil, errNoSigningKey
+}
+
+// current returns the signing key unless it is due for rotation, a.mu must be held.
+func (a *KeySetAuthenticator) current(now time.Time) *signingKey {
+	if len(a.keys) == 0 {
+		return nil
+	}
+
+	key := a.keys[0]
+	if !key.retiredAt.IsZero() || now.Sub(key.createdAt) >= a.rotation {
+		return nil
+	}
+
+	return key
+}
+
+// rotate stores a new signing key, or keeps the one another instance just created, then
+// reloads the keys. a.mu must be held for writing.
+func (a *KeySetAuthenticator) rotate(now time.Time) error {
+	key, err := a.newKey(now)
+	if err != nil {
+		return err
+	}
+
+	stored, err := a.seal(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyStoreTimeout)
+	defer cancel()
+
+	if err := a.store.Rotate(ctx, stored, now.Add(-a.rotation), now.Add(-a.retention)); err != nil {
+		return err
+	}
+
+	return a.reload(now)
+}
+
+// reload replaces the keys with the stored ones, a.mu must be held for writing.
+func (a *KeySetAuthenticator) reload(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), keyStoreTimeout)
+	defer cancel()
+
+	stored, err := a.store.Keys(ctx, a.method.Alg(), now.Add(-a.retention))
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*signingKey, 0, len(stored))
+	for _, sk := range stored {
+		key, err := a.open(sk)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", sk.ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	a.keys = keys
+	a.loadedAt = now
+	return nil
+}
+
+func (a *KeySetAuthenticator) verificationKey(kid string, now time.Time) *signingKey {
+	a.mu.RLock()
+	key, loadedAt := a.findKey(kid, now), a.loadedAt
+	a.mu.RUnlock()
+
+	if key != nil || now.Sub(loadedAt) < keyReloadMinInterval {
+		return key
+	}
+
+	// the key may have been created by another instance since the last reload
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if now.Sub(a.loadedAt) >= keyReloadMinInterval {
+		// on failure the keys loaded before are kept
+		_ = a.reload(now)
+	}
+
+	return a.findKey(kid, now)
+}
+
+// findKey returns the key with the id if it still verifies tokens, a.mu must be held.
+func (a *KeySetAuthenticator) findKey(kid string, now time.Time) *signingKey {
+	for _, key := range a.keys {
+		if key.id != kid {
+			continue
+		}
+
+		if !key.retiredAt.IsZero() && now.Sub(key.retiredAt) > a.retention {
+			return nil
+		}
+
+		return key
+	}
+
+	return nil
+}
+
+// seal encrypts the private key for the store, the key id is authenticated with it.
+func (a *KeySetAuthenticator) seal(key *signingKey) (StoredKey, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.signer)
+	if err != nil {
+		return StoredKey{}, err
+	}
+
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return StoredKey{}, err
+	}
+
+	return StoredKey{
+		ID:         key.id,
+		Algorithm:  a.method.Alg(),
+		PrivateKey: a.aead.Seal(nonce, nonce, der, []byte(key.id)),
+		CreatedAt:  key.createdAt,
+	}, nil
+}
+
+func (a *KeySetAuthenticator) open(stored StoredKey) (*signingKey, error) {
+	size := a.aead.NonceSize()
+	if len(stored.PrivateKey) < size {
+		return nil, errors.New("malformed private key")
+	}
+
+	nonce, sealed := stored.PrivateKey[:size], stored.PrivateKey[size:]
+	der, err := a.aead.Open(nil, nonce, sealed, []byte(stored.ID))
+	if err != nil {
+		return nil, errors.New("can't decrypt private key, check JWT_KEY_SECRET")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+
+	key := &signingKey{
+		id:        stored.ID,
+		signer:    signer,
+		createdAt: stored.CreatedAt,
+	}
+	if stored.RetiredAt != nil {
+		key.retiredAt = *stored.RetiredAt
+	}
+
+	return key, nil
+}
+
+// deriveKey turns the configured secret into an AES-256 key.
+func deriveKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+func (a *KeySetAuthenticator) newKey(now time.Time) (*signingKey, error) {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+
+	switch a.method.Alg() {
+	case AlgorithmRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &signingKey{
+		id:        hex.EncodeToString(id),
+		signer:    signer,
+		createdAt: now,
+	}, nil
+}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func publicJWK(key *signingKey, alg string) JWK {
+	jwk := JWK{
+		Kid: key.id,
+		Use: "sig",
+		Alg: alg,
+	}
+
+	switch pub := key.signer.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+
+	return jwk
+}
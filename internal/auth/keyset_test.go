@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// memKeyStore is a KeyStore shared by the authenticators of a test, as the database is by instances.
+type memKeyStore struct {
+	mu   sync.Mutex
+	keys []StoredKey // newest first
+}
+
+func (s *memKeyStore) Keys(ctx context.Context, algorithm string, retiredSince time.Time) ([]StoredKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []StoredKey{}
+	for _, k := range s.keys {
+		if k.Algorithm == algorithm && (k.RetiredAt == nil || k.RetiredAt.After(retiredSince)) {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys, nil
+}
+
+func (s *memKeyStore) Rotate(ctx context.Context, key StoredKey, dueBefore, retiredBefore time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.keys {
+		if k.Algorithm == key.Algorithm && k.RetiredAt == nil && k.CreatedAt.After(dueBefore) {
+			return nil
+		}
+	}
+
+	keys := []StoredKey{key}
+	for _, k := range s.keys {
+		if k.RetiredAt == nil {
+			k.RetiredAt = &key.CreatedAt
+		}
+		if k.RetiredAt.Before(retiredBefore) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	s.keys = keys
+
+	return nil
+}
+
+func newTestKeySet(t *testing.T, store KeyStore, secret string) *KeySetAuthenticator {
+	t.Helper()
+
+	a, err := NewKeySetAuthenticator(AlgorithmEdDSA, time.Hour, 15*time.Minute, "aud", "iss", store, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a
+}
+
+func testToken(t *testing.T, a *KeySetAuthenticator) string {
+	t.Helper()
+
+	token, err := a.GenerateToken(jwt.MapClaims{
+		"sub": 1,
+		"aud": "aud",
+		"iss": "iss",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestKeySetSharesKeysAcrossInstances(t *testing.T) {
+	store := &memKeyStore{}
+
+	first := newTestKeySet(t, store, "secret")
+	token := testToken(t, first)
+
+	// a restart, or another replica, loads the same keys
+	second := newTestKeySet(t, store, "secret")
+	if _, err := second.ValidateToken(token); err != nil {
+		t.Fatalf("token signed by another instance rejected: %v", err)
+	}
+
+	if len(store.keys) != 1 {
+		t.Errorf("instances created %d keys, want 1", len(store.keys))
+	}
+
+	if a, b := first.JWKS(), second.JWKS(); len(a.Keys) != 1 || len(b.Keys) != 1 || a.Keys[0] != b.Keys[0] {
+		t.Errorf("instances publish different key sets: %v and %v", a, b)
+	}
+}
+
+func TestKeySetRetiredKeysKeepVerifying(t *testing.T) {
+	store := &memKeyStore{}
+
+	a := newTestKeySet(t, store, "secret")
+	token := testToken(t, a)
+
+	// the key is due, the next signature rotates it
+	a.mu.Lock()
+	a.keys[0].createdAt = time.Now().Add(-2 * time.Hour)
+	a.loadedAt = time.Time{}
+	store.keys[0].CreatedAt = a.keys[0].createdAt
+	a.mu.Unlock()
+
+	rotated := testToken(t, a)
+
+	restarted := newTestKeySet(t, store, "secret")
+	for _, tok := range []string{token, rotated} {
+		if _, err := restarted.ValidateToken(tok); err != nil {
+			t.Errorf("token rejected after rotation and restart: %v", err)
+		}
+	}
+
+	if got := len(restarted.JWKS().Keys); got != 2 {
+		t.Errorf("published %d keys, want the signing and the retired one", got)
+	}
+}
+
+func TestKeySetEncryptsStoredKeys(t *testing.T) {
+	store := &memKeyStore{}
+	newTestKeySet(t, store, "secret")
+
+	if _, err := NewKeySetAuthenticator(AlgorithmEdDSA, time.Hour, 15*time.Minute, "aud", "iss", store, "other"); err == nil {
+		t.Error("keys were loaded with the wrong secret")
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890".
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// the RFC lists 8 digit codes, 6 digit codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode(%d): %v", tt.unix, err)
+		}
+
+		if want := tt.code[len(tt.code)-TOTPDigits:]; got != want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := TOTPStep(now)
+
+	tests := []struct {
+		offset int64
+		valid  bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+
+	for _, tt := range tests {
+		code, err := totpCodeAt(rfc6238Secret, current+tt.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		step, ok := ValidateTOTP(rfc6238Secret, code, now)
+		if ok != tt.valid {
+			t.Errorf("step %+d: valid = %v, want %v", tt.offset, ok, tt.valid)
+		}
+
+		if ok && step != current+tt.offset {
+			t.Errorf("step %+d: matched step %d, want %d", tt.offset, step, current+tt.offset)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now); ok {
+			t.Errorf("ValidateTOTP(%q) accepted", code)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stored hashes, burned on use like mfa_recovery_codes
+	unused := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q isn't formatted as xxxxx-xxxxx", code)
+		}
+		unused[HashRecoveryCode(code)] = true
+	}
+
+	if len(unused) != len(codes) {
+		t.Fatalf("got %d distinct hashes for %d codes", len(unused), len(codes))
+	}
+
+	use := func(typed string) bool {
+		hash := HashRecoveryCode(typed)
+		if !unused[hash] {
+			return false
+		}
+		delete(unused, hash)
+		return true
+	}
+
+	// users may type the code without the dash, in upper case, with spaces around
+	typed := " " + strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")) + " "
+	if !use(typed) {
+		t.Fatalf("recovery code %q typed as %q wasn't accepted", codes[0], typed)
+	}
+
+	if use(codes[0]) {
+		t.Errorf("recovery code %q was accepted twice", codes[0])
+	}
+
+	if use("aaaaa-aaaaa") {
+		t.Error("unknown recovery code was accepted")
+	}
+
+	if !use(codes[1]) {
+		t.Errorf("recovery code %q wasn't accepted", codes[1])
+	}
+}
@@ -0,0 +1,75 @@
+// Package diff computes line based differences between two texts.
+package diff
+
+import "strings"
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Edit is a run of lines that are kept, inserted or deleted, in the order they appear.
+type Edit struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns the edits turning a into b, based on their longest common subsequence of lines.
+// Posts are short, so the quadratic table is fine here.
+func Lines(a, b string) []Edit {
+	x := split(a)
+	y := split(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var edits []Edit
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			edits = appendEdit(edits, OpEqual, x[i])
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = appendEdit(edits, OpDelete, x[i])
+			i++
+		default:
+			edits = appendEdit(edits, OpInsert, y[j])
+			j++
+		}
+	}
+
+	return edits
+}
+
+// appendEdit merges consecutive lines with the same op into a single edit.
+func appendEdit(edits []Edit, op, line string) []Edit {
+	if n := len(edits); n > 0 && edits[n-1].Op == op {
+		edits[n-1].Text += "\n" + line
+		return edits
+	}
+
+	return append(edits, Edit{Op: op, Text: line})
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
@@ -3,9 +3,11 @@ package mailer
 import "embed"
 
 const (
-	FromName            = "Connection Sphere"
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName              = "Connection Sphere"
+	maxRetries            = 3
+	UserWelcomeTemplate   = "user_invitation.tmpl"
+	PasswordResetTemplate = "password_reset.tmpl"
+	EmailChangeTemplate   = "email_change.tmpl"
 )
 
 //go:embed "templates"
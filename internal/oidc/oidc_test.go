@@ -0,0 +1,236 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/saikumaradapa/Connection-Sphere/internal/oidc"
+	"github.com/saikumaradapa/Connection-Sphere/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://localhost:3030/v1/authentication/oidc/callback"
+
+func newProvider(t *testing.T) (*oidctest.Server, *oidc.Provider) {
+	t.Helper()
+
+	server := oidctest.NewServer("client", "secret")
+	t.Cleanup(server.Close)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       server.Issuer(),
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+	}, server.Client())
+
+	return server, provider
+}
+
+// authorize runs the redirect to the issuer and returns the code it sends back.
+func authorize(t *testing.T, server *oidctest.Server, provider *oidc.Provider, state, nonce, verifier string) string {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorization returned %d", res.StatusCode)
+	}
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := callback.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+
+	return callback.Query().Get("code")
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	_, provider := newProvider(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        oidc.CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, q.Get(k), v)
+		}
+	}
+
+	if q.Has("code_verifier") {
+		t.Error("the PKCE verifier was sent to the authorization endpoint")
+	}
+
+	if _, err := provider.AuthCodeURL(context.Background(), "", "nonce", "verifier"); !errors.Is(err, oidc.ErrEmptyParameters) {
+		t.Errorf("empty state: err = %v, want %v", err, oidc.ErrEmptyParameters)
+	}
+}
+
+func TestCodeChallengeRFC7636(t *testing.T) {
+	// RFC 7636 Appendix B
+	got := oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("CodeChallenge = %q, want %q", got, want)
+	}
+}
+
+func TestExchangeAndVerify(t *testing.T) {
+	server, provider := newProvider(t)
+	server.Identity = oidctest.Identity{Subject: "42", Email: "ada@example.com", EmailVerified: true, PreferredUsername: "ada"}
+
+	ctx := context.Background()
+	code := authorize(t, server, provider, "state", "nonce", "verifier")
+
+	rawIDToken, err := provider.Exchange(ctx, code, "verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, "nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+
+	if claims.Issuer != server.Issuer() || claims.Subject != "42" || claims.Email != "ada@example.com" ||
+		!claims.EmailVerified || claims.PreferredUsername != "ada" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	// codes are single use
+	if _, err := provider.Exchange(ctx, code, "verifier"); err == nil {
+		t.Error("the authorization code was exchanged twice")
+	}
+}
+
+func TestExchangeChecksPKCEVerifier(t *testing.T) {
+	server, provider := newProvider(t)
+
+	code := authorize(t, server, provider, "state", "nonce", "verifier")
+
+	if _, err := provider.Exchange(context.Background(), code, "another verifier"); err == nil {
+		t.Error("code exchanged with the wrong PKCE verifier")
+	}
+}
+
+func TestExchangeAuthenticatesClient(t *testing.T) {
+	server, _ := newProvider(t)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       server.Issuer(),
+		ClientID:     "client",
+		ClientSecret: "wrong",
+		RedirectURL:  redirectURL,
+	}, server.Client())
+
+	code := authorize(t, server, provider, "state", "nonce", "verifier")
+
+	if _, err := provider.Exchange(context.Background(), code, "verifier"); err == nil {
+		t.Error("code exchanged with the wrong client secret")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	server, provider := newProvider(t)
+	identity := server.Identity
+
+	tests := []struct {
+		name  string
+		nonce string
+		edit  func(jwt.MapClaims)
+		err   error
+	}{
+		{name: "valid", nonce: "nonce"},
+		{name: "wrong nonce", nonce: "other", err: oidc.ErrNonceMismatch},
+		{name: "wrong issuer", nonce: "nonce", edit: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, err: jwt.ErrTokenInvalidIssuer},
+		{name: "wrong audience", nonce: "nonce", edit: func(c jwt.MapClaims) { c["aud"] = "another-client" }, err: jwt.ErrTokenInvalidAudience},
+		{name: "expired", nonce: "nonce", edit: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, err: jwt.ErrTokenExpired},
+		{name: "no expiry", nonce: "nonce", edit: func(c jwt.MapClaims) { delete(c, "exp") }, err: jwt.ErrTokenRequiredClaimMissing},
+		{name: "issued in the future", nonce: "nonce", edit: func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() }, err: jwt.ErrTokenUsedBeforeIssued},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := server.IDToken(identity, "nonce", tt.edit)
+
+			_, err := provider.VerifyIDToken(context.Background(), raw, tt.nonce)
+			if tt.err == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsForgedSignature(t *testing.T) {
+	server, provider := newProvider(t)
+
+	// signed by another issuer using the same kid
+	other := oidctest.NewServer("client", "secret")
+	defer other.Close()
+
+	raw := other.IDToken(server.Identity, "nonce", func(c jwt.MapClaims) { c["iss"] = server.Issuer() })
+
+	if _, err := provider.VerifyIDToken(context.Background(), raw, "nonce"); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("err = %v, want %v", err, jwt.ErrTokenSignatureInvalid)
+	}
+}
+
+func TestDiscoveryChecksIssuer(t *testing.T) {
+	// a document claiming to be another issuer, as a misconfigured or malicious server would
+	impostor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"issuer": "https://accounts.example.com", "authorization_endpoint": "https://accounts.example.com/authorize"}`))
+	}))
+	defer impostor.Close()
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:      impostor.URL,
+		ClientID:    "client",
+		RedirectURL: redirectURL,
+	}, impostor.Client())
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); !errors.Is(err, oidc.ErrIssuerMismatch) {
+		t.Errorf("err = %v, want %v", err, oidc.ErrIssuerMismatch)
+	}
+}
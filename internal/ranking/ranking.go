@@ -0,0 +1,133 @@
+// Package ranking orders feed posts by relevance to a viewer. Scoring is left to a Strategy,
+// so strategies can be swapped by configuration, and the clock is injectable so a ranking
+// can be reproduced.
+package ranking
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Candidate is a post to rank, with the signals strategies score it on.
+type Candidate struct {
+	PostID    int64
+	AuthorID  int64
+	CreatedAt time.Time
+	Reactions int
+	Comments  int
+	Tags      []string
+}
+
+// Viewer is what is known of the tastes of the user the feed is ranked for. Affinity (by
+// author) and Interests (by tag) are between 0 and 1.
+type Viewer struct {
+	ID        int64
+	Affinity  map[int64]float64
+	Interests map[string]float64
+}
+
+// NewViewer normalizes the interaction counts of the user by the largest of each kind.
+func NewViewer(id int64, byAuthor map[int64]int, byTag map[string]int) Viewer {
+	return Viewer{
+		ID:        id,
+		Affinity:  normalize(byAuthor),
+		Interests: normalize(byTag),
+	}
+}
+
+func normalize[K comparable](counts map[K]int) map[K]float64 {
+	highest := 0
+	for _, n := range counts {
+		highest = max(highest, n)
+	}
+
+	normalized := make(map[K]float64, len(counts))
+	for k, n := range counts {
+		normalized[k] = float64(n) / float64(highest)
+	}
+
+	return normalized
+}
+
+// Score is the score of a candidate, Components explains it factor by factor.
+type Score struct {
+	Total      float64            `json:"total"`
+	Components map[string]float64 `json:"components"`
+}
+
+// Strategy scores candidates for a viewer, higher scores rank first.
+type Strategy interface {
+	Name() string
+	Score(c Candidate, v Viewer, now time.Time) Score
+}
+
+// New returns the strategy registered under the name.
+func New(name string) (Strategy, error) {
+	switch name {
+	case Weighted{}.Name():
+		return Weighted{DefaultWeights}, nil
+	case Chronological{}.Name():
+		return Chronological{}, nil
+	default:
+		return nil, fmt.Errorf("unknown ranking strategy %q", name)
+	}
+}
+
+// Clock returns the current time, rankings depend on it through recency.
+type Clock func() time.Time
+
+type Ranker struct {
+	strategy Strategy
+	clock    Clock
+}
+
+// NewRanker ranks with the strategy, clock defaults to time.Now.
+func NewRanker(strategy Strategy, clock Clock) *Ranker {
+	if clock == nil {
+		clock = time.Now
+	}
+
+	return &Ranker{strategy: strategy, clock: clock}
+}
+
+// Ranked is the candidate at Index in the ranked slice, with its score.
+type Ranked struct {
+	Index int
+	Score Score
+}
+
+func (r *Ranker) Strategy() string {
+	return r.strategy.Name()
+}
+
+func (r *Ranker) Now() time.Time {
+	return r.clock()
+}
+
+// Rank orders the candidates by descending score. Ties go to the newest post, then the
+// highest id, so the same input always ranks the same.
+func (r *Ranker) Rank(candidates []Candidate, v Viewer) []Ranked {
+	now := r.clock()
+
+	ranked := make([]Ranked, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = Ranked{Index: i, Score: r.strategy.Score(c, v, now)}
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Score.Total != b.Score.Total {
+			return a.Score.Total > b.Score.Total
+		}
+
+		ca, cb := candidates[a.Index], candidates[b.Index]
+		if !ca.CreatedAt.Equal(cb.CreatedAt) {
+			return ca.CreatedAt.After(cb.CreatedAt)
+		}
+
+		return ca.PostID > cb.PostID
+	})
+
+	return ranked
+}
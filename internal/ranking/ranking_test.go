@@ -0,0 +1,207 @@
+package ranking
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func fixedClock() time.Time {
+	return testNow
+}
+
+// rankedIDs returns the post ids in ranked order.
+func rankedIDs(r *Ranker, candidates []Candidate, v Viewer) []int64 {
+	ids := []int64{}
+	for _, ranked := range r.Rank(candidates, v) {
+		ids = append(ids, candidates[ranked.Index].PostID)
+	}
+
+	return ids
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestWeightedRecencyHalfLife(t *testing.T) {
+	w := Weighted{DefaultWeights}
+
+	tests := []struct {
+		age     time.Duration
+		recency float64
+	}{
+		{0, 1},
+		{DefaultWeights.HalfLife, 0.5},
+		{2 * DefaultWeights.HalfLife, 0.25},
+		{3 * DefaultWeights.HalfLife, 0.125},
+		{-time.Hour, 1}, // created after now, clocks of the database and the API differ
+	}
+
+	for _, tt := range tests {
+		score := w.Score(Candidate{PostID: 1, CreatedAt: testNow.Add(-tt.age)}, Viewer{}, testNow)
+
+		if got := score.Components["recency"]; !almostEqual(got, tt.recency) {
+			t.Errorf("recency at age %v = %v, want %v", tt.age, got, tt.recency)
+		}
+
+		// without engagement nor viewer signals the score is the recency alone
+		if !almostEqual(score.Total, tt.recency) {
+			t.Errorf("total at age %v = %v, want %v", tt.age, score.Total, tt.recency)
+		}
+	}
+}
+
+func TestWeightedBoosts(t *testing.T) {
+	w := Weighted{DefaultWeights}
+	v := NewViewer(1, map[int64]int{10: 4, 20: 2}, map[string]int{"go": 5, "rust": 1})
+	createdAt := testNow.Add(-DefaultWeights.HalfLife)
+
+	tests := []struct {
+		name      string
+		candidate Candidate
+		component string
+		boost     float64
+	}{
+		{"reactions", Candidate{Reactions: 3}, "reactions", DefaultWeights.Reactions * math.Log(4)},
+		{"comments", Candidate{Comments: 1}, "comments", DefaultWeights.Comments * math.Log(2)},
+		{"top author", Candidate{AuthorID: 10}, "affinity", DefaultWeights.Affinity},
+		{"other author", Candidate{AuthorID: 20}, "affinity", DefaultWeights.Affinity * 0.5},
+		{"unknown author", Candidate{AuthorID: 30}, "affinity", 0},
+		{"top interest", Candidate{Tags: []string{"rust", "go"}}, "interests", DefaultWeights.Interests},
+		{"minor interest", Candidate{Tags: []string{"rust"}}, "interests", DefaultWeights.Interests * 0.2},
+		{"unknown tag", Candidate{Tags: []string{"java"}}, "interests", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.candidate.CreatedAt = createdAt
+			score := w.Score(tt.candidate, v, testNow)
+
+			if got := score.Components[tt.component]; !almostEqual(got, tt.boost) {
+				t.Errorf("%s = %v, want %v", tt.component, got, tt.boost)
+			}
+
+			// boosts add up before the decay
+			if want := 0.5 * (1 + tt.boost); !almostEqual(score.Total, want) {
+				t.Errorf("total = %v, want %v", score.Total, want)
+			}
+		})
+	}
+}
+
+func TestWeightedRank(t *testing.T) {
+	v := NewViewer(1, map[int64]int{10: 1}, map[string]int{"go": 1})
+	r := NewRanker(Weighted{DefaultWeights}, fixedClock)
+
+	candidates := []Candidate{
+		{PostID: 1, AuthorID: 20, CreatedAt: testNow.Add(-time.Hour)},
+		{PostID: 2, AuthorID: 20, CreatedAt: testNow.Add(-2 * time.Hour), Reactions: 50, Comments: 20},
+		{PostID: 3, AuthorID: 10, CreatedAt: testNow.Add(-2 * time.Hour)},
+		{PostID: 4, AuthorID: 20, CreatedAt: testNow.Add(-2 * time.Hour), Tags: []string{"go"}},
+		{PostID: 5, AuthorID: 10, CreatedAt: testNow.Add(-72 * time.Hour), Reactions: 50, Comments: 20},
+	}
+
+	// engagement first, then affinity, then interests, all ahead of a slightly newer plain post;
+	// a post six half-lives old loses to all of them whatever its boosts
+	want := []int64{2, 3, 4, 1, 5}
+	if got := rankedIDs(r, candidates, v); !slices.Equal(got, want) {
+		t.Errorf("ranked %v, want %v", got, want)
+	}
+}
+
+func TestRankTieBreaks(t *testing.T) {
+	r := NewRanker(constant{}, fixedClock)
+
+	candidates := []Candidate{
+		{PostID: 1, CreatedAt: testNow.Add(-time.Hour)},
+		{PostID: 2, CreatedAt: testNow.Add(-2 * time.Hour)},
+		{PostID: 3, CreatedAt: testNow.Add(-time.Hour)},
+		{PostID: 4, CreatedAt: testNow},
+	}
+
+	// equal scores rank newest first, then by descending id
+	want := []int64{4, 3, 1, 2}
+	if got := rankedIDs(r, candidates, Viewer{}); !slices.Equal(got, want) {
+		t.Errorf("ranked %v, want %v", got, want)
+	}
+
+	// the order doesn't depend on the input order
+	slices.Reverse(candidates)
+	if got := rankedIDs(r, candidates, Viewer{}); !slices.Equal(got, want) {
+		t.Errorf("ranked reversed input %v, want %v", got, want)
+	}
+}
+
+func TestChronological(t *testing.T) {
+	r := NewRanker(Chronological{}, fixedClock)
+	v := NewViewer(1, map[int64]int{10: 1}, map[string]int{"go": 1})
+
+	candidates := []Candidate{
+		{PostID: 1, AuthorID: 10, CreatedAt: testNow.Add(-3 * time.Hour), Reactions: 100, Tags: []string{"go"}},
+		{PostID: 2, AuthorID: 20, CreatedAt: testNow.Add(-time.Hour)},
+		{PostID: 3, AuthorID: 20, CreatedAt: testNow.Add(-2 * time.Hour)},
+		{PostID: 4, AuthorID: 20, CreatedAt: testNow.Add(-time.Hour)},
+	}
+
+	// engagement and viewer signals are ignored, same age goes to the highest id
+	want := []int64{4, 2, 3, 1}
+	if got := rankedIDs(r, candidates, v); !slices.Equal(got, want) {
+		t.Errorf("ranked %v, want %v", got, want)
+	}
+
+	score := Chronological{}.Score(candidates[0], v, testNow)
+	if score.Total != -3 || score.Components["age_hours"] != 3 {
+		t.Errorf("score = %+v, want total -3 and age_hours 3", score)
+	}
+}
+
+func TestRankerClock(t *testing.T) {
+	if got := NewRanker(Chronological{}, fixedClock).Now(); !got.Equal(testNow) {
+		t.Errorf("Now() = %v, want %v", got, testNow)
+	}
+
+	// recency follows the injected clock, not the wall time
+	later := func() time.Time { return testNow.Add(48 * time.Hour) }
+	candidates := []Candidate{
+		{PostID: 1, CreatedAt: testNow, Reactions: 1},
+		{PostID: 2, CreatedAt: testNow.Add(-6 * time.Hour), Reactions: 20},
+	}
+
+	if got := rankedIDs(NewRanker(Weighted{DefaultWeights}, fixedClock), candidates, Viewer{}); got[0] != 2 {
+		t.Errorf("ranked %v at now, want the engaged post first", got)
+	}
+
+	for _, ranked := range NewRanker(Weighted{DefaultWeights}, later).Rank(candidates, Viewer{}) {
+		if ranked.Score.Components["recency"] >= 0.1 {
+			t.Errorf("post %d still has recency %v two days later", candidates[ranked.Index].PostID, ranked.Score.Components["recency"])
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"weighted", "chronological"} {
+		s, err := New(name)
+		if err != nil || s.Name() != name {
+			t.Errorf("New(%q) = %v, %v", name, s, err)
+		}
+	}
+
+	if _, err := New("random"); err == nil {
+		t.Error("New accepted an unknown strategy")
+	}
+}
+
+// constant scores every candidate the same, leaving the order to the tie-breaks.
+type constant struct{}
+
+func (constant) Name() string {
+	return "constant"
+}
+
+func (constant) Score(Candidate, Viewer, time.Time) Score {
+	return Score{Total: 1}
+}
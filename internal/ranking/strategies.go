@@ -0,0 +1,76 @@
+package ranking
+
+import (
+	"math"
+	"time"
+)
+
+// Weights tune the Weighted strategy. A post loses half of its score every HalfLife.
+type Weights struct {
+	HalfLife  time.Duration
+	Reactions float64
+	Comments  float64
+	Affinity  float64
+	Interests float64
+}
+
+var DefaultWeights = Weights{
+	HalfLife:  12 * time.Hour,
+	Reactions: 1,
+	Comments:  1.5,
+	Affinity:  2,
+	Interests: 1,
+}
+
+// Weighted boosts posts by engagement, by how much the viewer interacts with their author
+// and by how close their tags are to the interests of the viewer, then decays the result
+// with the age of the post. Engagement is logarithmic so that popular posts don't take over.
+type Weighted struct {
+	Weights
+}
+
+func (Weighted) Name() string {
+	return "weighted"
+}
+
+func (w Weighted) Score(c Candidate, v Viewer, now time.Time) Score {
+	age := max(now.Sub(c.CreatedAt), 0)
+	recency := math.Pow(0.5, age.Hours()/w.HalfLife.Hours())
+
+	reactions := w.Reactions * math.Log1p(float64(c.Reactions))
+	comments := w.Comments * math.Log1p(float64(c.Comments))
+	affinity := w.Affinity * v.Affinity[c.AuthorID]
+
+	interest := 0.0
+	for _, tag := range c.Tags {
+		interest = max(interest, v.Interests[tag])
+	}
+	interests := w.Interests * interest
+
+	return Score{
+		Total: recency * (1 + reactions + comments + affinity + interests),
+		Components: map[string]float64{
+			"recency":   recency,
+			"reactions": reactions,
+			"comments":  comments,
+			"affinity":  affinity,
+			"interests": interests,
+		},
+	}
+}
+
+// Chronological ranks newest first, like the desc feed, as a baseline for the others.
+type Chronological struct{}
+
+func (Chronological) Name() string {
+	return "chronological"
+}
+
+func (Chronological) Score(c Candidate, v Viewer, now time.Time) Score {
+	age := now.Sub(c.CreatedAt).Hours()
+
+	return Score{
+		Total:      -age,
+		Components: map[string]float64{"age_hours": age},
+	}
+}
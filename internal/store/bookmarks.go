@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+// Add saves the post for the user, saving it again changes nothing.
+func (s *BookmarkStore) Add(ctx context.Context, userID, postID int64) error {
+	query := `
+		INSERT INTO bookmarks (user_id, post_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, post_id) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove unsaves the post, removing a missing bookmark isn't an error.
+func (s *BookmarkStore) Remove(ctx context.Context, userID, postID int64) error {
+	query := `
+		DELETE FROM bookmarks
+		WHERE user_id = $1 AND post_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetByUser lists the posts saved by the user. Sort, since and until apply to the time the
+// posts were saved, tags match posts having any of them.
+func (s *BookmarkStore) GetByUser(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	// Ensure sort direction is safe
+	sortDirection := "DESC"
+	if strings.ToUpper(fq.Sort) == "ASC" {
+		sortDirection = "ASC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, p.kind, p.original_id, p.status,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+			b.created_at
+		FROM bookmarks b
+		JOIN posts p ON p.id = b.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE
+			b.user_id = $1 AND
+			(p.title ILIKE '%%' || $4 || '%%' OR p.content ILIKE '%%' || $4 || '%%') AND
+			(cardinality($5::varchar[]) = 0 OR p.tags && $5) AND
+			($6::timestamptz IS NULL OR b.created_at >= $6) AND
+			($7::timestamptz IS NULL OR b.created_at <= $7) AND
+			%s
+		ORDER BY b.created_at %s, p.id %s
+		LIMIT $2 OFFSET $3
+	`, fq.afterCondition("b.created_at", "p.id", sortDirection, 8), sortDirection, sortDirection)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := []any{
+		userID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		pq.Array(fq.Tags),
+		nullTime(fq.Since),
+		nullTime(fq.Until),
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, fq.afterArgs()...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		p := PostWithMetadata{Bookmarked: true}
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Kind,
+			&p.OriginalID,
+			&p.Status,
+			&p.User.Username,
+			&p.CommentCount,
+			&p.BookmarkedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.User.ID = p.UserID
+		posts = append(posts, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := embedFeedOriginals(ctx, s.db, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetBookmarked tells which of the posts the user saved.
+func (s *BookmarkStore) GetBookmarked(ctx context.Context, userID int64, postIDs []int64) (map[int64]bool, error) {
+	query := `
+		SELECT post_id
+		FROM bookmarks
+		WHERE user_id = $1 AND post_id = ANY($2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarked := make(map[int64]bool, len(postIDs))
+	for rows.Next() {
+		var postID int64
+		if err := rows.Scan(&postID); err != nil {
+			return nil, err
+		}
+		bookmarked[postID] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return bookmarked, nil
+}
+
+// nullTime turns the empty since/until of a query into NULL, so the filter is skipped.
+func nullTime(t string) sql.NullString {
+	return sql.NullString{String: t, Valid: t != ""}
+}
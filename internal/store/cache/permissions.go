@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// permissionExpTime bounds how long a change to role_permissions takes to apply
+const permissionExpTime = 5 * time.Minute
+
+// PermissionStore caches the permissions of each role in Redis.
+type PermissionStore struct {
+	rdb *redis.Client
+}
+
+// Get returns the cached permissions of a role, or nil on a cache miss.
+func (c *PermissionStore) Get(ctx context.Context, roleID int64) ([]string, error) {
+	cacheKey := fmt.Sprintf("role-permissions-%d", roleID)
+
+	data, err := c.rdb.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var permissions []string
+	if err := json.Unmarshal([]byte(data), &permissions); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (c *PermissionStore) Set(ctx context.Context, roleID int64, permissions []string) error {
+	cacheKey := fmt.Sprintf("role-permissions-%d", roleID)
+
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		return err
+	}
+
+	return c.rdb.SetEX(ctx, cacheKey, data, permissionExpTime).Err()
+}
+
+// MemoryPermissionStore caches the permissions of each role in process,
+// for deployments running without Redis.
+type MemoryPermissionStore struct {
+	mu      sync.RWMutex
+	entries map[int64]permissionEntry
+}
+
+type permissionEntry struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+func NewMemoryPermissionStore() *MemoryPermissionStore {
+	return &MemoryPermissionStore{entries: make(map[int64]permissionEntry)}
+}
+
+func (c *MemoryPermissionStore) Get(ctx context.Context, roleID int64) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[roleID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, nil
+	}
+
+	return entry.permissions, nil
+}
+
+func (c *MemoryPermissionStore) Set(ctx context.Context, roleID int64, permissions []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[roleID] = permissionEntry{
+		permissions: permissions,
+		expiresAt:   time.Now().Add(permissionExpTime),
+	}
+
+	return nil
+}
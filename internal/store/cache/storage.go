@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/saikumaradapa/Connection-Sphere/internal/store"
@@ -11,11 +12,32 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
+		Delete(context.Context, int64) error
+	}
+	Tokens interface {
+		Revoke(ctx context.Context, jti string, expiry time.Time) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
+	}
+	Permissions interface {
+		Get(ctx context.Context, roleID int64) ([]string, error)
+		Set(ctx context.Context, roleID int64, permissions []string) error
+	}
+	Timelines interface {
+		Push(ctx context.Context, userIDs []int64, entry store.TimelineEntry) error
+		Build(ctx context.Context, userID int64, entries []store.TimelineEntry) error
+		Read(ctx context.Context, userID int64, after *store.FeedPosition, limit int) (TimelinePage, error)
+		Remove(ctx context.Context, userID int64, postIDs []int64) error
+		Delete(context.Context, int64) error
+		MarkCelebrity(context.Context, int64) error
+		Celebrities(context.Context, []int64) ([]int64, error)
 	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rdb},
+		Users:       &UserStore{rdb: rdb},
+		Tokens:      &RevokedTokenStore{rdb: rdb},
+		Permissions: &PermissionStore{rdb: rdb},
+		Timelines:   &TimelineStore{rdb: rdb},
 	}
 }
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/saikumaradapa/Connection-Sphere/internal/store"
+)
+
+const (
+	// TimelineMaxLength is how many posts a timeline keeps, older pages are read from SQL.
+	TimelineMaxLength = 800
+	timelineExpTime   = 7 * 24 * time.Hour // timelines of inactive users are dropped
+
+	// timelineMarker marks a timeline as built, so that empty timelines exist too. Its
+	// score is below any post and trimming skips it.
+	timelineMarker = "built"
+
+	celebritiesKey = "timeline-celebrities"
+)
+
+// pushScript adds a post to the timelines that exist, the others are built from SQL on their
+// next read. Pushing to a missing timeline would make it look built with a single post.
+var pushScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if redis.call('EXISTS', key) == 1 then
+		redis.call('ZADD', key, ARGV[1], ARGV[2])
+		redis.call('ZREMRANGEBYRANK', key, 1, -(tonumber(ARGV[3]) + 1))
+	end
+end
+return 0
+`)
+
+// pushBatchSize bounds the keys given to a single script run.
+const pushBatchSize = 500
+
+// TimelineStore keeps the feed of each user as a sorted set of post ids scored by creation
+// time, in microseconds like Postgres timestamps.
+type TimelineStore struct {
+	rdb *redis.Client
+}
+
+// TimelinePage is a read from a timeline.
+type TimelinePage struct {
+	Entries []store.TimelineEntry
+	// Built is false when the timeline doesn't exist, it has to be built first.
+	Built bool
+	// Truncated tells that older posts were trimmed, a short page doesn't mean the end.
+	Truncated bool
+}
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline-%d", userID)
+}
+
+// timelineMember pads ids so that posts created at the same time sort by id, like in SQL.
+func timelineMember(postID int64) string {
+	return fmt.Sprintf("%019d", postID)
+}
+
+// Push adds the post to the timelines of the users.
+func (c *TimelineStore) Push(ctx context.Context, userIDs []int64, entry store.TimelineEntry) error {
+	for start := 0; start < len(userIDs); start += pushBatchSize {
+		end := min(start+pushBatchSize, len(userIDs))
+
+		keys := make([]string, 0, end-start)
+		for _, id := range userIDs[start:end] {
+			keys = append(keys, timelineKey(id))
+		}
+
+		err := pushScript.Run(ctx, c.rdb, keys, entry.CreatedAt.UnixMicro(), timelineMember(entry.PostID), TimelineMaxLength).Err()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Build replaces the timeline of the user with the entries.
+func (c *TimelineStore) Build(ctx context.Context, userID int64, entries []store.TimelineEntry) error {
+	key := timelineKey(userID)
+
+	members := make([]*redis.Z, 0, len(entries)+1)
+	members = append(members, &redis.Z{Score: 0, Member: timelineMarker})
+	for _, e := range entries {
+		members = append(members, &redis.Z{Score: float64(e.CreatedAt.UnixMicro()), Member: timelineMember(e.PostID)})
+	}
+
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.ZAdd(ctx, key, members...)
+		pipe.Expire(ctx, key, timelineExpTime)
+		return nil
+	})
+
+	return err
+}
+
+// Read returns up to limit entries after the position, newest first.
+func (c *TimelineStore) Read(ctx context.Context, userID int64, after *store.FeedPosition, limit int) (TimelinePage, error) {
+	key := timelineKey(userID)
+
+	size, err := c.rdb.ZCard(ctx, key).Result()
+	if err != nil {
+		return TimelinePage{}, err
+	}
+
+	if size == 0 {
+		return TimelinePage{}, nil
+	}
+
+	page := TimelinePage{
+		Built:     true,
+		Truncated: size-1 >= TimelineMaxLength,
+	}
+
+	maxScore := "+inf"
+	if after != nil {
+		createdAt, err := time.Parse(time.RFC3339Nano, after.CreatedAt)
+		if err != nil {
+			return TimelinePage{}, err
+		}
+		score := strconv.FormatInt(createdAt.UnixMicro(), 10)
+
+		// posts created the same microsecond as the position, only the ones after its id
+		ties, err := c.rdb.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Max: score, Min: score}).Result()
+		if err != nil {
+			return TimelinePage{}, err
+		}
+		for _, e := range parseTimeline(ties) {
+			if e.PostID < after.ID && len(page.Entries) < limit {
+				page.Entries = append(page.Entries, e)
+			}
+		}
+
+		maxScore = "(" + score
+	}
+
+	if remaining := limit - len(page.Entries); remaining > 0 {
+		older, err := c.rdb.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+			Max:   maxScore,
+			Min:   "(0",
+			Count: int64(remaining),
+		}).Result()
+		if err != nil {
+			return TimelinePage{}, err
+		}
+		page.Entries = append(page.Entries, parseTimeline(older)...)
+	}
+
+	// reading keeps the timeline of active users around
+	if err := c.rdb.Expire(ctx, key, timelineExpTime).Err(); err != nil {
+		return TimelinePage{}, err
+	}
+
+	return page, nil
+}
+
+// Remove drops posts from the timeline of the user, for posts deleted since they were pushed.
+func (c *TimelineStore) Remove(ctx context.Context, userID int64, postIDs []int64) error {
+	members := make([]any, len(postIDs))
+	for i, id := range postIDs {
+		members[i] = timelineMember(id)
+	}
+
+	return c.rdb.ZRem(ctx, timelineKey(userID), members...).Err()
+}
+
+// Delete drops the timeline of the user, it is built again on the next read.
+func (c *TimelineStore) Delete(ctx context.Context, userID int64) error {
+	return c.rdb.Del(ctx, timelineKey(userID)).Err()
+}
+
+// MarkCelebrity records that the posts of the user aren't pushed to timelines, readers
+// following them fetch their posts from SQL instead. Accounts stay marked, posts pushed
+// before are deduplicated by readers.
+func (c *TimelineStore) MarkCelebrity(ctx context.Context, userID int64) error {
+	return c.rdb.SAdd(ctx, celebritiesKey, userID).Err()
+}
+
+// Celebrities returns the marked accounts among the users.
+func (c *TimelineStore) Celebrities(ctx context.Context, userIDs []int64) ([]int64, error) {
+	marked, err := c.rdb.SMembers(ctx, celebritiesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	celebrities := make(map[int64]bool, len(marked))
+	for _, m := range marked {
+		id, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		celebrities[id] = true
+	}
+
+	found := []int64{}
+	for _, id := range userIDs {
+		if celebrities[id] {
+			found = append(found, id)
+		}
+	}
+
+	return found, nil
+}
+
+func parseTimeline(members []redis.Z) []store.TimelineEntry {
+	entries := make([]store.TimelineEntry, 0, len(members))
+	for _, m := range members {
+		member, ok := m.Member.(string)
+		if !ok || member == timelineMarker {
+			continue
+		}
+
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, store.TimelineEntry{PostID: id, CreatedAt: time.UnixMicro(int64(m.Score))})
+	}
+
+	return entries
+}
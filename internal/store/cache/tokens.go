@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RevokedTokenStore is the Redis denylist of access tokens, keyed by their jti claim.
+type RevokedTokenStore struct {
+	rdb *redis.Client
+}
+
+// Revoke denies the token until it expires on its own, the key expires with it.
+func (c *RevokedTokenStore) Revoke(ctx context.Context, jti string, expiry time.Time) error {
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		// already expired, nothing left to deny
+		return nil
+	}
+
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+
+	return c.rdb.SetEX(ctx, cacheKey, 1, ttl).Err()
+}
+
+func (c *RevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+
+	n, err := c.rdb.Exists(ctx, cacheKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
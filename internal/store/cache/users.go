@@ -12,6 +12,12 @@ import (
 
 const userExpTime = time.Minute
 
+// userEntry is the cached shape of a user, it keeps the fields hidden from api responses.
+type userEntry struct {
+	*store.User
+	TokenGeneration int `json:"token_generation"`
+}
+
 // UserCache provides caching for User objects in Redis.
 type UserStore struct {
 	rdb *redis.Client
@@ -31,22 +37,30 @@ func (c *UserStore) Get(ctx context.Context, userID int64) (*store.User, error)
 		return nil, err
 	}
 
-	var user store.User
-	if err := json.Unmarshal([]byte(data), &user); err != nil {
+	entry := userEntry{User: &store.User{}}
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
 		return nil, err
 	}
+	entry.User.TokenGeneration = entry.TokenGeneration
 
-	return &user, nil
+	return entry.User, nil
 }
 
 // Set stores a User in Redis with an expiration.
 func (c *UserStore) Set(ctx context.Context, user *store.User) error {
 	cacheKey := fmt.Sprintf("user-%d", user.ID)
 
-	userJSON, err := json.Marshal(user)
+	userJSON, err := json.Marshal(userEntry{User: user, TokenGeneration: user.TokenGeneration})
 	if err != nil {
 		return err
 	}
 
 	return c.rdb.SetEX(ctx, cacheKey, userJSON, userExpTime).Err()
 }
+
+// Delete evicts a User from Redis, the next read goes to the database.
+func (c *UserStore) Delete(ctx context.Context, userID int64) error {
+	cacheKey := fmt.Sprintf("user-%d", userID)
+
+	return c.rdb.Del(ctx, cacheKey).Err()
+}
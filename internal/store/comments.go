@@ -3,34 +3,81 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// MaxCommentDepth caps how deep reply chains go, replies to the deepest comments are refused.
+const MaxCommentDepth = 10
+
+var ErrCommentTooDeep = errors.New("this thread is too deep to reply to")
+
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID         int64      `json:"id"`
+	PostID     int64      `json:"post_id"`
+	UserID     int64      `json:"user_id"`
+	ParentID   *int64     `json:"parent_id"` // nil for top-level comments
+	Depth      int        `json:"depth"`
+	Content    string     `json:"content"`
+	CreatedAt  string     `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at"` // nil until the content is edited
+	ReplyCount int        `json:"reply_count"`
+	User       User       `json:"user"`
+
+	// Replies is only filled when fetching a thread. NextRepliesCursor is set when some
+	// replies were left out, it loads the rest from /comments/{id}/replies.
+	Replies           []Comment `json:"replies,omitempty"`
+	NextRepliesCursor string    `json:"next_replies_cursor,omitempty"`
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+const commentColumns = `
+	c.id, c.post_id, c.user_id, c.parent_id, c.depth, c.content, c.created_at, c.edited_at,
+	(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id),
+	users.username, users.id
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanComment(row rowScanner, c *Comment) error {
+	return row.Scan(
+		&c.ID,
+		&c.PostID,
+		&c.UserID,
+		&c.ParentID,
+		&c.Depth,
+		&c.Content,
+		&c.CreatedAt,
+		&c.EditedAt,
+		&c.ReplyCount,
+		&c.User.Username,
+		&c.User.ID,
+	)
+}
+
+// GetByPostID returns a page of the top-level comments of a post, replies are counted but not loaded.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID int64, cq PaginatedCommentsQuery) ([]Comment, error) {
 	query := `
-		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id
-		FROM comments c 
-		JOIN users 
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users
 		ON users.id = c.user_id
-		WHERE c.post_id = $1
-		ORDER BY c.created_at DESC;
+		WHERE c.post_id = $1 AND c.parent_id IS NULL
+		ORDER BY c.created_at ` + cq.Sort + `, c.id ` + cq.Sort + `
+		LIMIT $2 OFFSET $3
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, cq.Limit, cq.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -40,21 +87,147 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 	comments := []Comment{}
 	for rows.Next() {
 		var c Comment
-		c.User = User{}
-		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.User.Username, &c.User.ID)
-		if err != nil {
+		if err := scanComment(rows, &c); err != nil {
 			return nil, err
 		}
 		comments = append(comments, c)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return comments, nil
 }
 
+func (s *CommentStore) GetByID(ctx context.Context, commentID int64) (*Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users
+		ON users.id = c.user_id
+		WHERE c.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var c Comment
+	if err := scanComment(s.db.QueryRowContext(ctx, query, commentID), &c); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// GetReplies returns the direct replies of each parent, oldest first, starting after the
+// cursor (a reply id, 0 for the beginning). At most limit+1 replies are returned per parent
+// so callers can tell whether more are left.
+func (s *CommentStore) GetReplies(ctx context.Context, parentIDs []int64, cursor int64, limit int) ([]Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY id) AS rn
+			FROM comments
+			WHERE parent_id = ANY($1) AND id > $2
+		) t
+		JOIN comments c ON c.id = t.id
+		JOIN users
+		ON users.id = c.user_id
+		WHERE t.rn <= $3
+		ORDER BY c.parent_id, c.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(parentIDs), cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replies := []Comment{}
+	for rows.Next() {
+		var c Comment
+		if err := scanComment(rows, &c); err != nil {
+			return nil, err
+		}
+		replies = append(replies, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return replies, nil
+}
+
+// GetThread loads the comment with its replies as a tree, at most depth levels below it
+// and limit replies per comment. Truncated comments get a NextRepliesCursor.
+func (s *CommentStore) GetThread(ctx context.Context, commentID int64, depth, limit int) (*Comment, error) {
+	root, err := s.GetByID(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	level := []*Comment{root}
+	for i := 0; i < depth && len(level) > 0; i++ {
+		parents := make(map[int64]*Comment, len(level))
+		ids := make([]int64, 0, len(level))
+		for _, c := range level {
+			if c.ReplyCount == 0 {
+				continue
+			}
+			parents[c.ID] = c
+			ids = append(ids, c.ID)
+		}
+
+		if len(ids) == 0 {
+			break
+		}
+
+		replies, err := s.GetReplies(ctx, ids, 0, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, reply := range replies {
+			parent := parents[*reply.ParentID]
+			if len(parent.Replies) == limit {
+				parent.NextRepliesCursor = replyCursor(parent.Replies[limit-1].ID)
+				continue
+			}
+			parent.Replies = append(parent.Replies, reply)
+		}
+
+		// pointers are taken once every slice is complete, appends may move the elements
+		level = level[:0]
+		for _, id := range ids {
+			parent := parents[id]
+			for j := range parent.Replies {
+				level = append(level, &parent.Replies[j])
+			}
+		}
+	}
+
+	return root, nil
+}
+
+// replyCursor points after the given reply, the next page starts with the reply that follows it.
+func replyCursor(replyID int64) string {
+	return strconv.FormatInt(replyID, 10)
+}
+
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 	query := `
-		INSERT INTO comments (post_id, user_id, content)
-		VALUES ($1, $2, $3)
+		INSERT INTO comments (post_id, user_id, parent_id, depth, content)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at
 	`
 
@@ -66,6 +239,8 @@ func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 		query,
 		comment.PostID,
 		comment.UserID,
+		comment.ParentID,
+		comment.Depth,
 		comment.Content,
 	).Scan(
 		&comment.ID,
@@ -78,3 +253,52 @@ func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 
 	return nil
 }
+
+// Update saves the new content and marks the comment as edited.
+func (s *CommentStore) Update(ctx context.Context, comment *Comment) error {
+	query := `
+		UPDATE comments
+		SET content = $1, edited_at = NOW()
+		WHERE id = $2
+		RETURNING edited_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, comment.Content, comment.ID).Scan(&comment.EditedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the comment and, through the parent_id foreign key, its replies.
+func (s *CommentStore) Delete(ctx context.Context, commentID int64) error {
+	query := `DELETE FROM comments WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, commentID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled" // published by the scheduler once publish_at is reached
+	PostStatusPublished = "published"
+)
+
+// GetDrafts lists the drafts and scheduled posts of the author, newest first by default.
+func (s *PostStore) GetDrafts(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]Post, error) {
+	// Ensure sort direction is safe
+	sortDirection := "DESC"
+	if strings.ToUpper(fq.Sort) == "ASC" {
+		sortDirection = "ASC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, title, content, created_at, tags, updated_at, version, kind, original_id, status, publish_at
+		FROM posts
+		WHERE user_id = $1 AND status <> 'published' AND %s
+		ORDER BY created_at %s, id %s
+		LIMIT $2 OFFSET $3
+	`, fq.afterCondition("created_at", "id", sortDirection, 4), sortDirection, sortDirection)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{userID, fq.Limit, fq.Offset}, fq.afterArgs()...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			pq.Array(&p.Tags),
+			&p.UpdatedAt,
+			&p.Version,
+			&p.Kind,
+			&p.OriginalID,
+			&p.Status,
+			&p.PublishAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return drafts, nil
+}
+
+// Publish publishes the post right away, or schedules it when publishAt is set. created_at
+// moves to the publication time, so the post shows up in feeds as new.
+func (s *PostStore) Publish(ctx context.Context, post *Post, publishAt *time.Time) error {
+	query := `
+		UPDATE posts
+		SET
+			status = CASE WHEN $2::timestamptz IS NULL THEN 'published' ELSE 'scheduled' END,
+			publish_at = COALESCE($2, NOW()),
+			created_at = CASE WHEN $2::timestamptz IS NULL THEN NOW() ELSE created_at END
+		WHERE id = $1 AND status <> 'published'
+		RETURNING status, publish_at, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, post.ID, publishAt).Scan(
+		&post.Status,
+		&post.PublishAt,
+		&post.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PublishDue publishes the scheduled posts whose time has come and returns them.
+func (s *PostStore) PublishDue(ctx context.Context) ([]Post, error) {
+	query := `
+		UPDATE posts
+		SET status = 'published', created_at = publish_at
+		WHERE status = 'scheduled' AND publish_at <= NOW()
+		RETURNING id, user_id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	published := []Post{}
+	for rows.Next() {
+		p := Post{Status: PostStatusPublished}
+		if err := rows.Scan(&p.ID, &p.UserID, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		published = append(published, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return published, nil
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TrendingTag is a tag with the published posts using it in the window, and their authors.
+type TrendingTag struct {
+	Tag     string `json:"tag"`
+	Posts   int    `json:"posts"`
+	Authors int    `json:"authors"`
+}
+
+// publicAuthor keeps the posts of inactive and restricted users out of public listings.
+const publicAuthor = `u.is_active = true AND ` + notRestricted
+
+// GetPublic returns the published posts of everyone, for visitors and users following no
+// one. It filters and pages like GetUserFeed, tag timelines match fq.Tags with the GIN index.
+func (s *PostStore) GetPublic(ctx context.Context, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	sortDirection := "DESC"
+	if strings.ToUpper(fq.Sort) == "ASC" {
+		sortDirection = "ASC"
+	}
+
+	query := fmt.Sprintf(`
+	SELECT
+		p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, p.kind, p.original_id, p.status,
+		u.username,
+		(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE
+		p.status = 'published' AND
+		%s AND
+		(p.title ILIKE '%%' || $3 || '%%' OR p.content ILIKE '%%' || $3 || '%%') AND
+		(cardinality($4::varchar[]) = 0 OR p.tags && $4) AND
+		($5::timestamptz IS NULL OR p.created_at >= $5) AND
+		($6::timestamptz IS NULL OR p.created_at <= $6) AND
+		%s
+	ORDER BY p.created_at %s, p.id %s
+	LIMIT $1 OFFSET $2
+`, publicAuthor, fq.afterCondition("p.created_at", "p.id", sortDirection, 7), sortDirection, sortDirection)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := []any{
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		pq.Array(fq.Tags),
+		nullTime(fq.Since),
+		nullTime(fq.Until),
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, fq.afterArgs()...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed := []PostWithMetadata{}
+	for rows.Next() {
+		var p PostWithMetadata
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Kind,
+			&p.OriginalID,
+			&p.Status,
+			&p.User.Username,
+			&p.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.User.ID = p.UserID
+		feed = append(feed, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := embedFeedOriginals(ctx, s.db, feed); err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// GetPopular returns the public posts published in the window, most reactions and comments
+// first, newest first among equals.
+func (s *PostStore) GetPopular(ctx context.Context, tq TrendingQuery) ([]PostWithMetadata, error) {
+	query := `
+	SELECT
+		p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, p.kind, p.original_id, p.status,
+		u.username,
+		(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+		(SELECT COUNT(*) FROM post_reactions r WHERE r.post_id = p.id) AS reactions_count
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE
+		p.status = 'published' AND
+		p.created_at >= $3 AND
+		` + publicAuthor + `
+	ORDER BY comments_count + reactions_count DESC, p.created_at DESC, p.id DESC
+	LIMIT $1 OFFSET $2
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, tq.Limit, tq.Offset, time.Now().Add(-tq.Window))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed := []PostWithMetadata{}
+	for rows.Next() {
+		var p PostWithMetadata
+		var reactions int
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Kind,
+			&p.OriginalID,
+			&p.Status,
+			&p.User.Username,
+			&p.CommentCount,
+			&reactions,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.User.ID = p.UserID
+		feed = append(feed, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := embedFeedOriginals(ctx, s.db, feed); err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// GetTrendingTags returns the tags of the public posts published in the window. Tags used
+// by more authors rank first, so that a single account can't make a tag trend.
+func (s *PostStore) GetTrendingTags(ctx context.Context, tq TrendingQuery) ([]TrendingTag, error) {
+	query := `
+	SELECT tag, COUNT(*) AS posts, COUNT(DISTINCT p.user_id) AS authors
+	FROM posts p
+	JOIN users u ON u.id = p.user_id,
+	unnest(p.tags) AS tag
+	WHERE
+		p.status = 'published' AND
+		p.created_at >= $3 AND
+		` + publicAuthor + `
+	GROUP BY tag
+	ORDER BY authors DESC, posts DESC, tag
+	LIMIT $1 OFFSET $2
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, tq.Limit, tq.Offset, time.Now().Add(-tq.Window))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Posts, &t.Authors); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
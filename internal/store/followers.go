@@ -59,3 +59,52 @@ func (s *FollowerStore) Unfollow(ctx context.Context, followerID int64, userID i
 	return nil
 
 }
+
+// GetFollowerIDs returns up to limit followers of the user.
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID int64, limit int) ([]int64, error) {
+	query := `
+		SELECT follower_id
+		FROM followers
+		WHERE user_id = $1
+		LIMIT $2
+	`
+
+	return s.queryIDs(ctx, query, userID, limit)
+}
+
+// GetFollowingIDs returns the users followed by the user.
+func (s *FollowerStore) GetFollowingIDs(ctx context.Context, followerID int64) ([]int64, error) {
+	query := `
+		SELECT user_id
+		FROM followers
+		WHERE follower_id = $1
+	`
+
+	return s.queryIDs(ctx, query, followerID)
+}
+
+func (s *FollowerStore) queryIDs(ctx context.Context, query string, args ...any) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var ErrIdentityAlreadyLinked = errors.New("this external account is already linked to a user")
+
+// Identity links an account of an external identity provider (issuer + subject) to a user.
+type Identity struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Issuer    string `json:"issuer"`
+	Subject   string `json:"subject"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+}
+
+type IdentityStore struct {
+	db *sql.DB
+}
+
+// GetUserID returns the user linked to the external account.
+func (s *IdentityStore) GetUserID(ctx context.Context, issuer, subject string) (int64, error) {
+	query := `SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int64
+	err := s.db.QueryRowContext(ctx, query, issuer, subject).Scan(&userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// Link attaches the external account to an existing user.
+func (s *IdentityStore) Link(ctx context.Context, identity *Identity) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return createIdentity(ctx, tx, identity)
+	})
+}
+
+func createIdentity(ctx context.Context, tx *sql.Tx, identity *Identity) error {
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		identity.UserID,
+		identity.Issuer,
+		identity.Subject,
+		identity.Email,
+	).Scan(
+		&identity.ID,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return ErrIdentityAlreadyLinked
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// InteractionCounts counts the reactions, comments and bookmarks of a user on the posts of
+// other users, by author and by tag. Feed ranking derives the tastes of the user from them.
+type InteractionCounts struct {
+	ByAuthor map[int64]int
+	ByTag    map[string]int
+}
+
+type InteractionStore struct {
+	db *sql.DB
+}
+
+// interactionsQuery selects the posts the user ($1) interacted with since $2, once per interaction.
+const interactionsQuery = `
+	SELECT p.user_id, p.tags
+	FROM (
+		SELECT post_id FROM post_reactions WHERE user_id = $1 AND created_at >= $2
+		UNION ALL
+		SELECT post_id FROM comments WHERE user_id = $1 AND created_at >= $2
+		UNION ALL
+		SELECT post_id FROM bookmarks WHERE user_id = $1 AND created_at >= $2
+	) i
+	JOIN posts p ON p.id = i.post_id
+	WHERE p.user_id <> $1
+`
+
+// maxInterestTags bounds the tags kept as interests, the most interacted with.
+const maxInterestTags = 50
+
+// GetByUser counts the interactions of the user since the given time.
+func (s *InteractionStore) GetByUser(ctx context.Context, userID int64, since time.Time) (*InteractionCounts, error) {
+	byAuthor := `
+		SELECT user_id, COUNT(*)
+		FROM (` + interactionsQuery + `) i
+		GROUP BY user_id
+	`
+
+	byTag := `
+		SELECT tag, COUNT(*)
+		FROM (` + interactionsQuery + `) i, unnest(i.tags) AS tag
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	counts := &InteractionCounts{
+		ByAuthor: map[int64]int{},
+		ByTag:    map[string]int{},
+	}
+
+	rows, err := s.db.QueryContext(ctx, byAuthor, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var authorID int64
+		var n int
+		if err := rows.Scan(&authorID, &n); err != nil {
+			return nil, err
+		}
+		counts.ByAuthor[authorID] = n
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tagRows, err := s.db.QueryContext(ctx, byTag, userID, since, maxInterestTags)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var tag string
+		var n int
+		if err := tagRows.Scan(&tag, &n); err != nil {
+			return nil, err
+		}
+		counts.ByTag[tag] = n
+	}
+
+	if err := tagRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
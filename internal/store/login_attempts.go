@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	LoginScopeAccount = "account"
+	LoginScopeIP      = "ip"
+	LoginScopeMFA     = "mfa" // second factor failures, keyed by user id
+)
+
+type LoginAttempt struct {
+	Scope       string     `json:"scope"`
+	Identifier  string     `json:"identifier"`
+	FailedCount int        `json:"failed_count"`
+	LockedUntil *time.Time `json:"locked_until"`
+}
+
+// LockoutPolicy decides after how many failures a key gets locked and for how long.
+// Every failure past the threshold doubles the lockout, capped at MaxDuration.
+// Counters of keys that haven't failed for MaxDuration start again from zero.
+type LockoutPolicy struct {
+	MaxAttempts int
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+// LockDuration returns how long a key with the given number of failures stays locked.
+func (p LockoutPolicy) LockDuration(failedCount int) time.Duration {
+	if p.MaxAttempts <= 0 || failedCount < p.MaxAttempts {
+		return 0
+	}
+
+	lock := p.Duration
+	for i := p.MaxAttempts; i < failedCount && lock < p.MaxDuration; i++ {
+		lock *= 2
+	}
+
+	if lock > p.MaxDuration {
+		lock = p.MaxDuration
+	}
+
+	return lock
+}
+
+type LoginAttemptStore struct {
+	db *sql.DB
+}
+
+// LockedUntil returns the time until which the key is locked, or nil if it is not locked.
+func (s *LoginAttemptStore) LockedUntil(ctx context.Context, scope, identifier string) (*time.Time, error) {
+	query := `
+		SELECT locked_until
+		FROM login_attempts
+		WHERE scope = $1 AND identifier = $2 AND locked_until > NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var lockedUntil time.Time
+	err := s.db.QueryRowContext(ctx, query, scope, identifier).Scan(&lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &lockedUntil, nil
+}
+
+// RegisterFailure counts a failed attempt for the key and locks it once the policy threshold is reached.
+func (s *LoginAttemptStore) RegisterFailure(ctx context.Context, scope, identifier string, policy LockoutPolicy) (*LoginAttempt, error) {
+	attempt := &LoginAttempt{
+		Scope:      scope,
+		Identifier: identifier,
+	}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO login_attempts (scope, identifier, failed_count, last_failed_at)
+			VALUES ($1, $2, 1, NOW())
+			ON CONFLICT (scope, identifier) DO UPDATE
+			SET failed_count = CASE
+					WHEN login_attempts.last_failed_at < NOW() - make_interval(secs => $3) THEN 1
+					ELSE login_attempts.failed_count + 1
+				END,
+				last_failed_at = NOW()
+			RETURNING failed_count
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, scope, identifier, policy.MaxDuration.Seconds()).Scan(&attempt.FailedCount)
+		if err != nil {
+			return err
+		}
+
+		lock := policy.LockDuration(attempt.FailedCount)
+		if lock == 0 {
+			return nil
+		}
+
+		query = `
+			UPDATE login_attempts
+			SET locked_until = NOW() + make_interval(secs => $3)
+			WHERE scope = $1 AND identifier = $2
+			RETURNING locked_until
+		`
+
+		var lockedUntil time.Time
+		if err := tx.QueryRowContext(ctx, query, scope, identifier, lock.Seconds()).Scan(&lockedUntil); err != nil {
+			return err
+		}
+		attempt.LockedUntil = &lockedUntil
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+// Reset forgets all failed attempts of the key.
+func (s *LoginAttemptStore) Reset(ctx context.Context, scope, identifier string) error {
+	query := `DELETE FROM login_attempts WHERE scope = $1 AND identifier = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, scope, identifier)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the keys that are no longer locked and whose failures are older than
+// the policy counts them, their next failure would start from zero anyway.
+func (s *LoginAttemptStore) DeleteExpired(ctx context.Context, policy LockoutPolicy) (int64, error) {
+	query := `
+		DELETE FROM login_attempts
+		WHERE (locked_until IS NULL OR locked_until < NOW())
+			AND last_failed_at < NOW() - make_interval(secs => $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, policy.MaxDuration.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
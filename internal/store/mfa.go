@@ -0,0 +1,213 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+)
+
+type TOTP struct {
+	UserID       int64  `json:"user_id"`
+	Secret       string `json:"-"`
+	Enabled      bool   `json:"enabled"`
+	LastUsedStep int64  `json:"-"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type MFAStore struct {
+	db *sql.DB
+}
+
+func (s *MFAStore) GetTOTP(ctx context.Context, userID int64) (*TOTP, error) {
+	query := `
+		SELECT user_id, secret, enabled, last_used_step, created_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var totp TOTP
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.Enabled,
+		&totp.LastUsedStep,
+		&totp.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &totp, nil
+}
+
+// SetTOTPSecret starts (or restarts) an enrollment. It is refused once the factor is enabled.
+func (s *MFAStore) SetTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_totp.enabled = false
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// EnableTOTP completes the enrollment with the step of the confirmed code and
+// replaces the recovery codes with the given hashes.
+func (s *MFAStore) EnableTOTP(ctx context.Context, userID int64, step int64, recoveryCodes []string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE user_totp
+			SET enabled = true, last_used_step = $2
+			WHERE user_id = $1 AND enabled = false
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, userID, step)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrMFAAlreadyEnabled
+		}
+
+		if err := s.deleteRecoveryCodes(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO mfa_recovery_codes (user_id, code) VALUES ($1, $2)`
+		for _, code := range recoveryCodes {
+			if _, err := tx.ExecContext(ctx, query, userID, code); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DisableTOTP removes the factor and its recovery codes.
+func (s *MFAStore) DisableTOTP(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `DELETE FROM user_totp WHERE user_id = $1`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		return s.deleteRecoveryCodes(ctx, tx, userID)
+	})
+}
+
+// UseTOTPStep records the step of an accepted code. A step at or before the last
+// used one means the code is being replayed and ErrInvalidToken is returned.
+func (s *MFAStore) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND enabled = true AND last_used_step < $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// UseRecoveryCode burns the recovery code with the given hash.
+func (s *MFAStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code = $2 AND used_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, code)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+func (s *MFAStore) deleteRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM mfa_recovery_codes WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
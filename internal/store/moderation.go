@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// notRestricted filters out banned users and users with a running suspension.
+// Columns are unqualified so it fits both aliased and plain queries on users.
+const notRestricted = `banned_at IS NULL AND (suspended_until IS NULL OR suspended_until <= NOW())`
+
+// Search lists every user, restricted and inactive ones included, for the admin api.
+func (s *UserStore) Search(ctx context.Context, q UserSearchQuery) ([]User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.created_at, u.is_active, u.role_id,
+			u.suspended_until, COALESCE(u.suspension_reason, ''), u.banned_at,
+			r.id, r.name, r.description, r.level
+		FROM users u
+		JOIN roles r ON u.role_id = r.id
+		WHERE ($1 = '' OR u.username ILIKE '%' || $1 || '%' OR u.email ILIKE '%' || $1 || '%')
+			AND ($2 = '' OR r.name = $2)
+			AND (
+				$3 = ''
+				OR ($3 = 'banned' AND u.banned_at IS NOT NULL)
+				OR ($3 = 'suspended' AND u.banned_at IS NULL AND u.suspended_until > NOW())
+				OR ($3 = 'inactive' AND u.is_active = false)
+				OR ($3 = 'active' AND u.is_active = true AND ` + notRestricted + `)
+			)
+		ORDER BY u.id
+		LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, q.Search, q.Role, q.Status, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		err := rows.Scan(
+			&u.ID,
+			&u.Username,
+			&u.Email,
+			&u.CreatedAt,
+			&u.IsActive,
+			&u.RoleID,
+			&u.SuspendedUntil,
+			&u.SuspensionReason,
+			&u.BannedAt,
+			&u.Role.ID,
+			&u.Role.Name,
+			&u.Role.Description,
+			&u.Role.Level,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (s *UserStore) SetRole(ctx context.Context, userID, roleID int64) error {
+	query := `UPDATE users SET role_id = $1 WHERE id = $2`
+
+	return s.moderate(ctx, query, roleID, userID)
+}
+
+// Suspend blocks the user until the given time, the suspension lifts by itself afterwards.
+func (s *UserStore) Suspend(ctx context.Context, userID int64, until time.Time, reason string) error {
+	query := `UPDATE users SET suspended_until = $1, suspension_reason = $2 WHERE id = $3`
+
+	return s.moderate(ctx, query, until, reason, userID)
+}
+
+// Ban blocks the user permanently, until an admin reactivates them.
+func (s *UserStore) Ban(ctx context.Context, userID int64, reason string) error {
+	query := `UPDATE users SET banned_at = NOW(), suspension_reason = $1 WHERE id = $2`
+
+	return s.moderate(ctx, query, reason, userID)
+}
+
+// Reactivate lifts suspensions and bans, and activates users that never accepted their invitation.
+func (s *UserStore) Reactivate(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET is_active = true, suspended_until = NULL, suspension_reason = NULL, banned_at = NULL
+		WHERE id = $1
+	`
+
+	return s.moderate(ctx, query, userID)
+}
+
+// moderate runs an update on a single user, ErrNotFound when the user doesn't exist.
+func (s *UserStore) moderate(ctx context.Context, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
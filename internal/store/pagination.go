@@ -1,6 +1,7 @@
 package store
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -15,6 +16,18 @@ type PaginatedFeedQuery struct {
 	Search string   `json:"search" validate:"max=100"`
 	Since  string   `json:"since"`
 	Until  string   `json:"until"`
+
+	// Cursor is the opaque next_cursor of the previous page, handlers verify it and set
+	// After. Offset is kept for older clients, the two can't be combined.
+	Cursor string        `json:"cursor" validate:"max=500"`
+	After  *FeedPosition `json:"-"`
+}
+
+// FeedPosition is the (created_at, id) key of the last item of a page, the next page
+// starts right after it, whatever was inserted in the meantime.
+type FeedPosition struct {
+	CreatedAt string `json:"t"`
+	ID        int64  `json:"id"`
 }
 
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
@@ -24,7 +37,7 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	if limit != "" {
 		l, err := strconv.Atoi(limit)
 		if err != nil {
-			return fq, nil
+			return fq, fmt.Errorf("invalid limit %q", limit)
 		}
 
 		fq.Limit = l
@@ -34,7 +47,7 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	if offset != "" {
 		l, err := strconv.Atoi(offset)
 		if err != nil {
-			return fq, nil
+			return fq, fmt.Errorf("invalid offset %q", offset)
 		}
 
 		fq.Offset = l
@@ -55,25 +68,244 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 		fq.Search = search
 	}
 
+	fq.Cursor = qs.Get("cursor")
+
 	since := qs.Get("since")
 	if since != "" {
-		fq.Since = parseTime(since)
+		t, err := parseTime(since)
+		if err != nil {
+			return fq, err
+		}
+
+		fq.Since = t
 	}
 
 	until := qs.Get("until")
 	if until != "" {
-		fq.Until = parseTime(until)
+		t, err := parseTime(until)
+		if err != nil {
+			return fq, err
+		}
+
+		fq.Until = t
 	}
 
 	return fq, nil
 
 }
 
-func parseTime(s string) string {
-	t, err := time.Parse(time.DateTime, s)
-	if err != nil {
-		return ""
+type PaginatedCommentsQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Sort   string `json:"sort" validate:"oneof=asc desc"`
+}
+
+func (cq PaginatedCommentsQuery) Parse(r *http.Request) (PaginatedCommentsQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Offset = o
+	}
+
+	sort := qs.Get("sort")
+	if sort != "" {
+		cq.Sort = sort
+	}
+
+	return cq, nil
+}
+
+// RepliesQuery pages through the replies of a comment, Cursor is the id of the last reply seen.
+type RepliesQuery struct {
+	Limit  int   `json:"limit" validate:"gte=1,lte=50"`
+	Cursor int64 `json:"cursor" validate:"gte=0"`
+}
+
+func (rq RepliesQuery) Parse(r *http.Request) (RepliesQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return rq, err
+		}
+
+		rq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			return rq, err
+		}
+
+		rq.Cursor = c
+	}
+
+	return rq, nil
+}
+
+// ThreadQuery bounds a thread, Depth levels of replies with at most Limit replies each.
+type ThreadQuery struct {
+	Depth int `json:"depth" validate:"gte=1,lte=5"`
+	Limit int `json:"limit" validate:"gte=1,lte=10"`
+}
+
+func (tq ThreadQuery) Parse(r *http.Request) (ThreadQuery, error) {
+	qs := r.URL.Query()
+
+	depth := qs.Get("depth")
+	if depth != "" {
+		d, err := strconv.Atoi(depth)
+		if err != nil {
+			return tq, err
+		}
+
+		tq.Depth = d
+	}
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return tq, err
+		}
+
+		tq.Limit = l
+	}
+
+	return tq, nil
+}
+
+// TrendingQuery pages through what trends among the posts published in the last Window.
+type TrendingQuery struct {
+	Limit  int           `json:"limit" validate:"gte=1,lte=50"`
+	Offset int           `json:"offset" validate:"gte=0"`
+	Window time.Duration `json:"window" validate:"gte=1h,lte=168h"`
+}
+
+func (tq TrendingQuery) Parse(r *http.Request) (TrendingQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return tq, fmt.Errorf("invalid limit %q", limit)
+		}
+
+		tq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return tq, fmt.Errorf("invalid offset %q", offset)
+		}
+
+		tq.Offset = o
+	}
+
+	window := qs.Get("window")
+	if window != "" {
+		w, err := time.ParseDuration(window)
+		if err != nil {
+			return tq, fmt.Errorf("invalid window %q", window)
+		}
+
+		tq.Window = w
+	}
+
+	return tq, nil
+}
+
+// UserSearchQuery filters the admin user listing.
+type UserSearchQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Search string `json:"search" validate:"max=100"`
+	Role   string `json:"role" validate:"max=255"`
+	Status string `json:"status" validate:"omitempty,oneof=active inactive suspended banned"`
+}
+
+func (uq UserSearchQuery) Parse(r *http.Request) (UserSearchQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return uq, err
+		}
+
+		uq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return uq, err
+		}
+
+		uq.Offset = o
+	}
+
+	uq.Search = qs.Get("search")
+	uq.Role = qs.Get("role")
+	uq.Status = qs.Get("status")
+
+	return uq, nil
+}
+
+// afterCondition restricts a query sorted by (timeColumn, idColumn) to the rows after
+// fq.After, it uses the args at positions arg and arg+1 given by afterArgs.
+func (fq PaginatedFeedQuery) afterCondition(timeColumn, idColumn, sortDirection string, arg int) string {
+	op := "<"
+	if sortDirection == "ASC" {
+		op = ">"
+	}
+
+	return fmt.Sprintf(
+		"($%d::timestamptz IS NULL OR (%s, %s) %s ($%d, $%d))",
+		arg, timeColumn, idColumn, op, arg, arg+1,
+	)
+}
+
+func (fq PaginatedFeedQuery) afterArgs() []any {
+	if fq.After == nil {
+		return []any{nil, nil}
+	}
+
+	return []any{fq.After.CreatedAt, fq.After.ID}
+}
+
+// parseTime accepts "2006-01-02 15:04:05" (UTC) or RFC 3339, and normalizes to RFC 3339.
+func parseTime(s string) (string, error) {
+	for _, layout := range []string{time.DateTime, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339), nil
+		}
 	}
 
-	return t.Format(time.DateTime)
+	return "", fmt.Errorf("invalid time %q, use RFC 3339 or %q", s, time.DateTime)
 }
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateTokenName = errors.New("a token with that name already exists")
+
+type PersonalAccessToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"-"` // sha256 hash of the token handed to the user
+	Scopes     []string   `json:"scopes"`
+	Expiry     *time.Time `json:"expiry"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+type PersonalTokenStore struct {
+	db *sql.DB
+}
+
+func (s *PersonalTokenStore) Create(ctx context.Context, token *PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token, scopes, expiry)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.Name,
+		token.Token,
+		pq.Array(token.Scopes),
+		token.Expiry,
+	).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return ErrDuplicateTokenName
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// GetByToken looks a token up by its hash, expired tokens included.
+func (s *PersonalTokenStore) GetByToken(ctx context.Context, hash string) (*PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, scopes, expiry, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE token = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var token PersonalAccessToken
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		pq.Array(&token.Scopes),
+		&token.Expiry,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &token, nil
+}
+
+func (s *PersonalTokenStore) GetByUserID(ctx context.Context, userID int64) ([]PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, scopes, expiry, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []PersonalAccessToken{}
+	for rows.Next() {
+		var t PersonalAccessToken
+		err := rows.Scan(
+			&t.ID,
+			&t.UserID,
+			&t.Name,
+			pq.Array(&t.Scopes),
+			&t.Expiry,
+			&t.LastUsedAt,
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes a token, only its owner can do it.
+func (s *PersonalTokenStore) Delete(ctx context.Context, userID, tokenID int64) error {
+	query := `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, tokenID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Touch records the token was used. Writes are skipped within a minute of the
+// previous one, so busy bots don't turn every request into an UPDATE.
+func (s *PersonalTokenStore) Touch(ctx context.Context, tokenID int64) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, tokenID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -21,17 +22,35 @@ type Post struct {
 	Version   int       `json:"version"`
 	Comments  []Comment `json:"comments"`
 	User      User      `json:"user"`
+
+	Reactions *ReactionSummary `json:"reactions,omitempty"` // filled by handlers for the viewer
+
+	// Reposts and quotes reference the original post, which is embedded when loaded.
+	// OriginalDeleted marks the tombstone left once the original is gone.
+	Kind            string `json:"kind"`
+	OriginalID      *int64 `json:"original_id"`
+	Original        *Post  `json:"original,omitempty"`
+	OriginalDeleted bool   `json:"original_deleted,omitempty"`
+
+	// Drafts and scheduled posts are only visible to their author.
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 type PostWithMetadata struct {
 	Post
-	CommentCount int `json:"comments_count"`
+	CommentCount int    `json:"comments_count"`
+	Bookmarked   bool   `json:"bookmarked"`
+	BookmarkedAt string `json:"bookmarked_at,omitempty"` // only set when listing bookmarks
 }
 
 type PostStore struct {
 	db *sql.DB
 }
 
+// GetUserFeed returns the published posts of the user and of the users they follow. Tags
+// match posts having any of them, since and until bound the creation time. Pages start
+// after fq.After when set, after fq.Offset rows otherwise.
 func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
 	// Ensure sort direction is safe
 	sortDirection := "DESC"
@@ -40,32 +59,44 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 	}
 
 	query := fmt.Sprintf(`
-	SELECT 
-		p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
+	SELECT
+		p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, p.kind, p.original_id, p.status,
 		u.username,
-		COUNT(c.id) AS comments_count
+		(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count
 	FROM posts p
-	LEFT JOIN comments c ON c.post_id = p.id
-	LEFT JOIN users u ON p.user_id = u.id
-	JOIN followers f ON f.follower_id = p.user_id AND f.user_id = $1
-	WHERE 
-		(p.user_id = $1 OR f.user_id = $1) AND 
-		(p.title ILIKE '%%' || $4 || '%%' OR p.content ILIKE '%%' || $4 || '%%')
-	GROUP BY p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, u.username
-	ORDER BY p.created_at %s
+	JOIN users u ON u.id = p.user_id
+	WHERE
+		p.status = 'published' AND
+		(p.user_id = $1 OR p.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)) AND
+		(p.title ILIKE '%%' || $4 || '%%' OR p.content ILIKE '%%' || $4 || '%%') AND
+		(cardinality($5::varchar[]) = 0 OR p.tags && $5) AND
+		($6::timestamptz IS NULL OR p.created_at >= $6) AND
+		($7::timestamptz IS NULL OR p.created_at <= $7) AND
+		%s
+	ORDER BY p.created_at %s, p.id %s
 	LIMIT $2 OFFSET $3
-`, sortDirection)
+`, fq.afterCondition("p.created_at", "p.id", sortDirection, 8), sortDirection, sortDirection)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Search)
+	args := []any{
+		userID,
+		fq.Limit,
+		fq.Offset,
+		fq.Search,
+		pq.Array(fq.Tags),
+		nullTime(fq.Since),
+		nullTime(fq.Until),
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, fq.afterArgs()...)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var feed []PostWithMetadata
+	feed := []PostWithMetadata{}
 	for rows.Next() {
 		var p PostWithMetadata
 		err := rows.Scan(
@@ -76,12 +107,16 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 			&p.CreatedAt,
 			&p.Version,
 			pq.Array(&p.Tags),
+			&p.Kind,
+			&p.OriginalID,
+			&p.Status,
 			&p.User.Username,
 			&p.CommentCount,
 		)
 		if err != nil {
 			return nil, err
 		}
+		p.User.ID = p.UserID
 		feed = append(feed, p)
 	}
 
@@ -89,15 +124,26 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 		return nil, err
 	}
 
+	if err := embedFeedOriginals(ctx, s.db, feed); err != nil {
+		return nil, err
+	}
+
 	return feed, nil
 }
 
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
 	query := `
-	INSERT INTO posts (content, title, user_id, tags)
-	VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+	INSERT INTO posts (content, title, user_id, tags, kind, original_id, status, publish_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at, updated_at
 	`
 
+	if post.Kind == "" {
+		post.Kind = PostKindPost
+	}
+	if post.Status == "" {
+		post.Status = PostStatusPublished
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
@@ -108,6 +154,10 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 		post.Title,
 		post.UserID,
 		pq.Array(post.Tags),
+		post.Kind,
+		post.OriginalID,
+		post.Status,
+		post.PublishAt,
 	).Scan(
 		&post.ID,
 		&post.CreatedAt,
@@ -123,7 +173,7 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 	// be explisit while extracting, easy to marshalling into json
 	query := `
-		SELECT id, user_id, title, content, created_at, tags, updated_at, version
+		SELECT id, user_id, title, content, created_at, tags, updated_at, version, kind, original_id, status, publish_at
 		FROM posts
 		WHERE id = $1
 	`
@@ -141,6 +191,10 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		pq.Array(&post.Tags),
 		&post.UpdatedAt,
 		&post.Version,
+		&post.Kind,
+		&post.OriginalID,
+		&post.Status,
+		&post.PublishAt,
 	)
 
 	if err != nil {
@@ -153,6 +207,10 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 
 	}
 
+	if err := embedOriginals(ctx, s.db, []*Post{&post}); err != nil {
+		return nil, err
+	}
+
 	return &post, nil
 }
 
@@ -180,7 +238,18 @@ func (s *PostStore) Delete(ctx context.Context, postID int64) error {
 	return nil
 }
 
+// Update saves the new title and content, the replaced version is kept in post_revisions.
 func (s *PostStore) Update(ctx context.Context, post *Post) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.createRevision(ctx, tx, post.ID, post.Version); err != nil {
+			return err
+		}
+
+		return s.update(ctx, tx, post)
+	})
+}
+
+func (s *PostStore) update(ctx context.Context, tx *sql.Tx, post *Post) error {
 	query := `
 		UPDATE posts
 		SET title = $1, content = $2, version = version + 1
@@ -190,7 +259,7 @@ func (s *PostStore) Update(ctx context.Context, post *Post) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
 		query,
 		post.Title,
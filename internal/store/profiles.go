@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+)
+
+// Profile holds what users tell about themselves, it is embedded in User.
+type Profile struct {
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	Website     string `json:"website"`
+	Location    string `json:"location"`
+}
+
+type UserStats struct {
+	FollowersCount int64 `json:"followers_count"`
+	FollowingCount int64 `json:"following_count"`
+	PostsCount     int64 `json:"posts_count"`
+}
+
+// PublicProfile is what anyone can see about a user.
+type PublicProfile struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+	Profile
+	UserStats
+}
+
+// PrivateProfile is the view of users on their own account.
+type PrivateProfile struct {
+	PublicProfile
+	Email string `json:"email"`
+	Role  Role   `json:"role"`
+}
+
+func (u *User) PublicProfile(stats UserStats) PublicProfile {
+	return PublicProfile{
+		ID:        u.ID,
+		Username:  u.Username,
+		CreatedAt: u.CreatedAt,
+		Profile:   u.Profile,
+		UserStats: stats,
+	}
+}
+
+func (u *User) PrivateProfile(stats UserStats) PrivateProfile {
+	return PrivateProfile{
+		PublicProfile: u.PublicProfile(stats),
+		Email:         u.Email,
+		Role:          u.Role,
+	}
+}
+
+// GetStats counts the followers, followed users and posts of a user.
+func (s *UserStore) GetStats(ctx context.Context, userID int64) (UserStats, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM followers WHERE user_id = $1),
+			(SELECT COUNT(*) FROM followers WHERE follower_id = $1),
+			(SELECT COUNT(*) FROM posts WHERE user_id = $1 AND status = 'published')
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var stats UserStats
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&stats.FollowersCount,
+		&stats.FollowingCount,
+		&stats.PostsCount,
+	)
+	if err != nil {
+		return UserStats{}, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// ReactionSummary aggregates the reactions of a post as seen by one viewer.
+type ReactionSummary struct {
+	Counts      map[string]int `json:"counts"`
+	Total       int            `json:"total"`
+	ReactedByMe bool           `json:"reacted_by_me"`
+	MyReaction  string         `json:"my_reaction,omitempty"`
+}
+
+func newReactionSummary() *ReactionSummary {
+	return &ReactionSummary{Counts: map[string]int{}}
+}
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// React sets the reaction of the user on the post. A user has at most one reaction per post,
+// reacting again replaces it, so repeating the call changes nothing.
+func (s *ReactionStore) React(ctx context.Context, postID, userID int64, reaction string) error {
+	query := `
+		INSERT INTO post_reactions (post_id, user_id, type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (post_id, user_id) DO UPDATE
+		SET type = EXCLUDED.type, created_at = NOW()
+		WHERE post_reactions.type <> EXCLUDED.type
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID, userID, reaction)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Unreact removes the reaction of the user, removing a missing reaction isn't an error.
+func (s *ReactionStore) Unreact(ctx context.Context, postID, userID int64) error {
+	query := `
+		DELETE FROM post_reactions
+		WHERE post_id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetSummaries counts the reactions of every post by type and marks the ones of the viewer.
+// Every requested post gets a summary, posts without reactions have empty counts.
+func (s *ReactionStore) GetSummaries(ctx context.Context, postIDs []int64, viewerID int64) (map[int64]*ReactionSummary, error) {
+	query := `
+		SELECT post_id, type, COUNT(*), BOOL_OR(user_id = $2)
+		FROM post_reactions
+		WHERE post_id = ANY($1)
+		GROUP BY post_id, type
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make(map[int64]*ReactionSummary, len(postIDs))
+	for _, id := range postIDs {
+		summaries[id] = newReactionSummary()
+	}
+
+	for rows.Next() {
+		var (
+			postID   int64
+			reaction string
+			count    int
+			mine     bool
+		)
+		if err := rows.Scan(&postID, &reaction, &count, &mine); err != nil {
+			return nil, err
+		}
+
+		summary := summaries[postID]
+		summary.Counts[reaction] = count
+		summary.Total += count
+		if mine {
+			summary.ReactedByMe = true
+			summary.MyReaction = reaction
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
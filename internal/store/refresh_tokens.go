@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrRefreshTokenExpired = errors.New("refresh token has expired")
+)
+
+type RefreshToken struct {
+	ID        int64     `json:"id"`
+	Token     string    `json:"-"` // sha256 hash of the token handed to the client
+	UserID    int64     `json:"user_id"`
+	FamilyID  string    `json:"family_id"`
+	Expiry    time.Time `json:"expiry"`
+	CreatedAt string    `json:"created_at"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, token)
+	})
+}
+
+// Rotate consumes the refresh token with the given hash and stores next in the same family.
+// Presenting a token that was already consumed or revoked means it leaked, so the whole
+// family is revoked and ErrRefreshTokenReused is returned.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, hash string, next *RefreshToken) error {
+	var reusedFamilyID string
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT id, user_id, family_id, expiry, used_at IS NOT NULL OR revoked_at IS NOT NULL
+			FROM refresh_tokens
+			WHERE token = $1
+			FOR UPDATE
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var (
+			current  RefreshToken
+			consumed bool
+		)
+
+		err := tx.QueryRowContext(ctx, query, hash).Scan(
+			&current.ID,
+			&current.UserID,
+			&current.FamilyID,
+			&current.Expiry,
+			&consumed,
+		)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		if consumed {
+			reusedFamilyID = current.FamilyID
+			return ErrRefreshTokenReused
+		}
+
+		if time.Now().After(current.Expiry) {
+			return ErrRefreshTokenExpired
+		}
+
+		query = `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, current.ID); err != nil {
+			return err
+		}
+
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+
+		return s.create(ctx, tx, next)
+	})
+
+	// the revocation must outlive the rolled back rotation
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if revokeErr := s.RevokeFamily(ctx, reusedFamilyID); revokeErr != nil {
+			return revokeErr
+		}
+	}
+
+	return err
+}
+
+// RevokeFamily revokes every refresh token issued for the same login.
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, familyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every refresh token of the user, ending all of their sessions.
+func (s *RefreshTokenStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the tokens of the families whose every token has expired. Used and
+// revoked tokens of live families are kept, presenting them again must still be detected.
+func (s *RefreshTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM refresh_tokens rt
+		WHERE rt.expiry < NOW()
+			AND NOT EXISTS (
+				SELECT 1 FROM refresh_tokens f
+				WHERE f.family_id = rt.family_id AND f.expiry >= NOW()
+			)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *RefreshTokenStore) create(ctx context.Context, tx *sql.Tx, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		token.Token,
+		token.UserID,
+		token.FamilyID,
+		token.Expiry,
+	).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	PostKindPost   = "post"
+	PostKindRepost = "repost" // a plain boost, no content of its own
+	PostKindQuote  = "quote"  // a new post that references another one
+)
+
+var (
+	ErrAlreadyReposted = errors.New("already reposted the post")
+	ErrOriginalDeleted = errors.New("the original post was deleted")
+)
+
+// Repost boosts the original post for the followers of the user.
+func (s *PostStore) Repost(ctx context.Context, userID, originalID int64) (*Post, error) {
+	post := &Post{
+		UserID:     userID,
+		Tags:       []string{},
+		Kind:       PostKindRepost,
+		OriginalID: &originalID,
+	}
+
+	if err := s.Create(ctx, post); err != nil {
+		// the partial unique index on (user_id, original_id) allows a single repost per user
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return nil, ErrAlreadyReposted
+		}
+
+		return nil, err
+	}
+
+	if err := embedOriginals(ctx, s.db, []*Post{post}); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// DeleteRepost undoes the repost of the original post by the user.
+func (s *PostStore) DeleteRepost(ctx context.Context, userID, originalID int64) error {
+	query := `
+		DELETE FROM posts
+		WHERE user_id = $1 AND original_id = $2 AND kind = 'repost'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, originalID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// getByIDs loads posts with their author, missing ids are left out.
+func getByIDs(ctx context.Context, db *sql.DB, ids []int64) (map[int64]*Post, error) {
+	query := `
+		SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.tags, p.updated_at, p.version, p.kind, p.original_id, p.status, p.publish_at,
+			u.username
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = ANY($1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make(map[int64]*Post, len(ids))
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			pq.Array(&p.Tags),
+			&p.UpdatedAt,
+			&p.Version,
+			&p.Kind,
+			&p.OriginalID,
+			&p.Status,
+			&p.PublishAt,
+			&p.User.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.User.ID = p.UserID
+		posts[p.ID] = &p
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// embedOriginals attaches the original post, with its author, to reposts and quotes.
+// Only one level is embedded: an original that quotes another post keeps just its original_id.
+func embedOriginals(ctx context.Context, db *sql.DB, posts []*Post) error {
+	ids := []int64{}
+	for _, p := range posts {
+		if p.OriginalID != nil {
+			ids = append(ids, *p.OriginalID)
+		}
+	}
+
+	var originals map[int64]*Post
+	if len(ids) > 0 {
+		var err error
+		if originals, err = getByIDs(ctx, db, ids); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range posts {
+		if p.Kind == PostKindPost || p.Kind == "" {
+			continue
+		}
+
+		// the foreign key clears original_id when the original is deleted
+		if p.OriginalID == nil || originals[*p.OriginalID] == nil {
+			p.OriginalDeleted = true
+			continue
+		}
+
+		p.Original = originals[*p.OriginalID]
+	}
+
+	return nil
+}
+
+func embedFeedOriginals(ctx context.Context, db *sql.DB, feed []PostWithMetadata) error {
+	posts := make([]*Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+
+	return embedOriginals(ctx, db, posts)
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// PostRevision is the text of a post at a version that was since replaced.
+type PostRevision struct {
+	PostID    int64    `json:"post_id"`
+	Version   int      `json:"version"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"created_at"` // when the version was replaced
+}
+
+// createRevision copies the current version of the post into post_revisions. The version
+// check doubles as the optimistic lock: two concurrent updates archive the same version
+// and the primary key rejects the second one.
+func (s *PostStore) createRevision(ctx context.Context, tx *sql.Tx, postID int64, version int) error {
+	query := `
+		INSERT INTO post_revisions (post_id, version, title, content, tags)
+		SELECT id, version, title, content, tags
+		FROM posts
+		WHERE id = $1 AND version = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, postID, version)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetRevisions lists the replaced versions of the post, newest first.
+func (s *PostStore) GetRevisions(ctx context.Context, postID int64) ([]PostRevision, error) {
+	query := `
+		SELECT post_id, version, title, content, tags, created_at
+		FROM post_revisions
+		WHERE post_id = $1
+		ORDER BY version DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var r PostRevision
+		err := rows.Scan(
+			&r.PostID,
+			&r.Version,
+			&r.Title,
+			&r.Content,
+			pq.Array(&r.Tags),
+			&r.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s *PostStore) GetRevision(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	query := `
+		SELECT post_id, version, title, content, tags, created_at
+		FROM post_revisions
+		WHERE post_id = $1 AND version = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var r PostRevision
+	err := s.db.QueryRowContext(ctx, query, postID, version).Scan(
+		&r.PostID,
+		&r.Version,
+		&r.Title,
+		&r.Content,
+		pq.Array(&r.Tags),
+		&r.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
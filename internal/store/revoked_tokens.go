@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RevokedTokenStore is the Postgres denylist of access tokens, keyed by their jti claim.
+type RevokedTokenStore struct {
+	db *sql.DB
+}
+
+// Revoke denies the token until it expires on its own, DeleteExpired purges it after that.
+func (s *RevokedTokenStore) Revoke(ctx context.Context, jti string, expiry time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, expiry)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, jti, expiry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *RevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revoked bool
+	if err := s.db.QueryRowContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpired removes the tokens past their expiry, they are rejected without the denylist.
+func (s *RevokedTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM revoked_tokens WHERE expiry < NOW()`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -6,6 +6,17 @@ import (
 	"errors"
 )
 
+// Permissions are named capabilities granted to roles through role_permissions,
+// "any" scoped ones apply to resources owned by other users.
+const (
+	PermissionPostsUpdateAny    = "posts:update:any"
+	PermissionPostsDeleteAny    = "posts:delete:any"
+	PermissionCommentsUpdateAny = "comments:update:any"
+	PermissionCommentsDeleteAny = "comments:delete:any"
+	PermissionUsersBan          = "users:ban"
+	PermissionUsersManage       = "users:manage"
+)
+
 type Role struct {
 	ID          int64  `json:"id"`
 	Name        string `json:"name"`
@@ -41,3 +52,38 @@ func (s *RoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
 	return role, nil
 
 }
+
+// GetPermissions returns the names of the permissions granted to the role.
+func (s *RoleStore) GetPermissions(ctx context.Context, roleID int64) ([]string, error) {
+	query := `
+		SELECT p.name
+		FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		WHERE rp.role_id = $1
+		ORDER BY p.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
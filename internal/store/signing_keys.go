@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SigningKey is an asymmetric JWT signing key, PrivateKey is encrypted by the authenticator.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey []byte
+	CreatedAt  time.Time
+	RetiredAt  *time.Time
+}
+
+type SigningKeyStore struct {
+	db *sql.DB
+}
+
+// GetByAlgorithm returns the signing key of the algorithm and the keys retired after
+// retiredSince, newest first.
+func (s *SigningKeyStore) GetByAlgorithm(ctx context.Context, algorithm string, retiredSince time.Time) ([]SigningKey, error) {
+	query := `
+		SELECT id, algorithm, private_key, created_at, retired_at
+		FROM signing_keys
+		WHERE algorithm = $1 AND (retired_at IS NULL OR retired_at > $2)
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, algorithm, retiredSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []SigningKey{}
+	for rows.Next() {
+		var k SigningKey
+		if err := rows.Scan(&k.ID, &k.Algorithm, &k.PrivateKey, &k.CreatedAt, &k.RetiredAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Rotate makes key the signing key of its algorithm, unless an instance already created one
+// after dueBefore, and deletes the keys retired before retiredBefore. Rotations are serialized
+// by a table lock, so instances that find the key due at the same time rotate it once.
+func (s *SigningKeyStore) Rotate(ctx context.Context, key *SigningKey, dueBefore, retiredBefore time.Time) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `LOCK TABLE signing_keys IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+
+		var rotated bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM signing_keys
+				WHERE algorithm = $1 AND retired_at IS NULL AND created_at > $2
+			)
+		`, key.Algorithm, dueBefore).Scan(&rotated)
+		if err != nil {
+			return err
+		}
+
+		if rotated {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE signing_keys SET retired_at = $2
+			WHERE algorithm = $1 AND retired_at IS NULL
+		`, key.Algorithm, key.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO signing_keys (id, algorithm, private_key, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, key.ID, key.Algorithm, key.PrivateKey, key.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM signing_keys WHERE algorithm = $1 AND retired_at < $2
+		`, key.Algorithm, retiredBefore)
+		return err
+	})
+}
@@ -8,14 +8,15 @@ import (
 )
 
 var (
-	ErrNotFound               = errors.New("resource not found")
-	QueryTimeoutDuration      = time.Second * 5
-	ErrAlreadyFollowing       = errors.New("already following the user")
-	ErrNotFollowing           = errors.New("not following the user")
-	ErrInvalidToken           = errors.New("invalid or missing token")
-	ErrActivationTokenExpired = errors.New("activation token has expired")
-	ErrUserMissingInContext   = errors.New("user missing in context")
-	ErrPostMissingInContext   = errors.New("post missing in context")
+	ErrNotFound                = errors.New("resource not found")
+	QueryTimeoutDuration       = time.Second * 5
+	ErrAlreadyFollowing        = errors.New("already following the user")
+	ErrNotFollowing            = errors.New("not following the user")
+	ErrInvalidToken            = errors.New("invalid or missing token")
+	ErrActivationTokenExpired  = errors.New("activation token has expired")
+	ErrUserMissingInContext    = errors.New("user missing in context")
+	ErrPostMissingInContext    = errors.New("post missing in context")
+	ErrCommentMissingInContext = errors.New("comment missing in context")
 )
 
 type Storage struct {
@@ -25,36 +26,139 @@ type Storage struct {
 		Delete(context.Context, int64) error
 		Update(context.Context, *Post) error
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		Repost(ctx context.Context, userID, originalID int64) (*Post, error)
+		DeleteRepost(ctx context.Context, userID, originalID int64) error
+		GetDrafts(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]Post, error)
+		Publish(ctx context.Context, post *Post, publishAt *time.Time) error
+		PublishDue(context.Context) ([]Post, error)
+		GetRevisions(context.Context, int64) ([]PostRevision, error)
+		GetRevision(ctx context.Context, postID int64, version int) (*PostRevision, error)
+		GetTimeline(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error)
+		GetTimelineByAuthors(ctx context.Context, authorIDs []int64, after *FeedPosition, limit int) ([]TimelineEntry, error)
+		GetFeedByIDs(context.Context, []int64) ([]PostWithMetadata, error)
+		GetPublic(context.Context, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetPopular(context.Context, TrendingQuery) ([]PostWithMetadata, error)
+		GetTrendingTags(context.Context, TrendingQuery) ([]TrendingTag, error)
 	}
 	Users interface {
 		GetByID(context.Context, int64) (*User, error)
 		GetByEmail(context.Context, string) (*User, error)
 		Create(context.Context, *sql.Tx, *User) error
 		CreateAndInvite(ctx context.Context, user *User, token string, duration time.Duration) error
+		CreateWithIdentity(ctx context.Context, user *User, identity *Identity) error
 		Activate(ctx context.Context, token string) error
 		Delete(context.Context, int64) error
+		IncrementTokenGeneration(context.Context, int64) error
+		CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error
+		ResetPassword(ctx context.Context, token, newPassword string) (*User, error)
+		RenewInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error)
+		DeleteExpiredInvitations(context.Context) (int64, error)
+		DeleteUnactivated(ctx context.Context, gracePeriod time.Duration) (int64, error)
+		Search(context.Context, UserSearchQuery) ([]User, error)
+		SetRole(ctx context.Context, userID, roleID int64) error
+		Suspend(ctx context.Context, userID int64, until time.Time, reason string) error
+		Ban(ctx context.Context, userID int64, reason string) error
+		Reactivate(context.Context, int64) error
+		UpdateProfile(context.Context, *User) error
+		GetStats(context.Context, int64) (UserStats, error)
+		ChangePassword(ctx context.Context, user *User, newPassword string) error
+		CreateEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error
+		ConfirmEmailChange(ctx context.Context, token string) (int64, error)
 	}
 	Comments interface {
 		Create(context.Context, *Comment) error
-		GetByPostID(context.Context, int64) ([]Comment, error)
+		GetByID(context.Context, int64) (*Comment, error)
+		GetByPostID(ctx context.Context, postID int64, cq PaginatedCommentsQuery) ([]Comment, error)
+		GetReplies(ctx context.Context, parentIDs []int64, cursor int64, limit int) ([]Comment, error)
+		GetThread(ctx context.Context, commentID int64, depth, limit int) (*Comment, error)
+		Update(context.Context, *Comment) error
+		Delete(context.Context, int64) error
 	}
 
 	Followers interface {
 		Follow(context.Context, int64, int64) error
 		Unfollow(context.Context, int64, int64) error
+		GetFollowerIDs(ctx context.Context, userID int64, limit int) ([]int64, error)
+		GetFollowingIDs(context.Context, int64) ([]int64, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
+		GetPermissions(ctx context.Context, roleID int64) ([]string, error)
+	}
+	LoginAttempts interface {
+		LockedUntil(ctx context.Context, scope, identifier string) (*time.Time, error)
+		RegisterFailure(ctx context.Context, scope, identifier string, policy LockoutPolicy) (*LoginAttempt, error)
+		Reset(ctx context.Context, scope, identifier string) error
+		DeleteExpired(ctx context.Context, policy LockoutPolicy) (int64, error)
+	}
+	RefreshTokens interface {
+		Create(context.Context, *RefreshToken) error
+		Rotate(ctx context.Context, hash string, next *RefreshToken) error
+		RevokeFamily(ctx context.Context, familyID string) error
+		RevokeAllForUser(ctx context.Context, userID int64) error
+		DeleteExpired(context.Context) (int64, error)
+	}
+	RevokedTokens interface {
+		Revoke(ctx context.Context, jti string, expiry time.Time) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
+		DeleteExpired(context.Context) (int64, error)
+	}
+	MFA interface {
+		GetTOTP(context.Context, int64) (*TOTP, error)
+		SetTOTPSecret(ctx context.Context, userID int64, secret string) error
+		EnableTOTP(ctx context.Context, userID int64, step int64, recoveryCodes []string) error
+		DisableTOTP(context.Context, int64) error
+		UseTOTPStep(ctx context.Context, userID int64, step int64) error
+		UseRecoveryCode(ctx context.Context, userID int64, code string) error
+	}
+	PersonalTokens interface {
+		Create(context.Context, *PersonalAccessToken) error
+		GetByToken(ctx context.Context, hash string) (*PersonalAccessToken, error)
+		GetByUserID(context.Context, int64) ([]PersonalAccessToken, error)
+		Delete(ctx context.Context, userID, tokenID int64) error
+		Touch(context.Context, int64) error
+	}
+	Identities interface {
+		GetUserID(ctx context.Context, issuer, subject string) (int64, error)
+		Link(context.Context, *Identity) error
+	}
+	Reactions interface {
+		React(ctx context.Context, postID, userID int64, reaction string) error
+		Unreact(ctx context.Context, postID, userID int64) error
+		GetSummaries(ctx context.Context, postIDs []int64, viewerID int64) (map[int64]*ReactionSummary, error)
+	}
+	Bookmarks interface {
+		Add(ctx context.Context, userID, postID int64) error
+		Remove(ctx context.Context, userID, postID int64) error
+		GetByUser(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetBookmarked(ctx context.Context, userID int64, postIDs []int64) (map[int64]bool, error)
+	}
+	Interactions interface {
+		GetByUser(ctx context.Context, userID int64, since time.Time) (*InteractionCounts, error)
+	}
+	SigningKeys interface {
+		GetByAlgorithm(ctx context.Context, algorithm string, retiredSince time.Time) ([]SigningKey, error)
+		Rotate(ctx context.Context, key *SigningKey, dueBefore, retiredBefore time.Time) error
 	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:          &PostStore{db},
+		Users:          &UserStore{db},
+		Comments:       &CommentStore{db},
+		Followers:      &FollowerStore{db},
+		Roles:          &RoleStore{db},
+		LoginAttempts:  &LoginAttemptStore{db},
+		RefreshTokens:  &RefreshTokenStore{db},
+		RevokedTokens:  &RevokedTokenStore{db},
+		MFA:            &MFAStore{db},
+		PersonalTokens: &PersonalTokenStore{db},
+		Identities:     &IdentityStore{db},
+		Reactions:      &ReactionStore{db},
+		Bookmarks:      &BookmarkStore{db},
+		Interactions:   &InteractionStore{db},
+		SigningKeys:    &SigningKeyStore{db},
 	}
 }
 
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TimelineEntry is a post as kept in materialized timelines, ordered by (CreatedAt, PostID).
+type TimelineEntry struct {
+	PostID    int64
+	CreatedAt time.Time
+}
+
+// GetTimeline returns the newest entries of the feed of the user, the same posts as an
+// unfiltered GetUserFeed. It builds timelines that aren't materialized yet.
+func (s *PostStore) GetTimeline(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		SELECT id, created_at
+		FROM posts
+		WHERE
+			status = 'published' AND
+			(user_id = $1 OR user_id IN (SELECT user_id FROM followers WHERE follower_id = $1))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	return s.queryTimeline(ctx, query, userID, limit)
+}
+
+// GetTimelineByAuthors returns the newest entries posted by the authors after the position,
+// for the accounts whose posts aren't pushed to timelines.
+func (s *PostStore) GetTimelineByAuthors(ctx context.Context, authorIDs []int64, after *FeedPosition, limit int) ([]TimelineEntry, error) {
+	fq := PaginatedFeedQuery{After: after}
+
+	query := `
+		SELECT id, created_at
+		FROM posts
+		WHERE
+			status = 'published' AND
+			user_id = ANY($1) AND
+			` + fq.afterCondition("created_at", "id", "DESC", 3) + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	args := append([]any{pq.Array(authorIDs), limit}, fq.afterArgs()...)
+	return s.queryTimeline(ctx, query, args...)
+}
+
+func (s *PostStore) queryTimeline(ctx context.Context, query string, args ...any) ([]TimelineEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TimelineEntry{}
+	for rows.Next() {
+		var e TimelineEntry
+		if err := rows.Scan(&e.PostID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetFeedByIDs hydrates timeline entries into feed items, in the order of the ids. Posts
+// deleted or unpublished since they were pushed are left out.
+func (s *PostStore) GetFeedByIDs(ctx context.Context, ids []int64) ([]PostWithMetadata, error) {
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, p.kind, p.original_id, p.status,
+			u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = ANY($1) AND p.status = 'published'
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[int64]PostWithMetadata, len(ids))
+	for rows.Next() {
+		var p PostWithMetadata
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.Version,
+			pq.Array(&p.Tags),
+			&p.Kind,
+			&p.OriginalID,
+			&p.Status,
+			&p.User.Username,
+			&p.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.User.ID = p.UserID
+		found[p.ID] = p
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	feed := make([]PostWithMetadata, 0, len(found))
+	for _, id := range ids {
+		if p, ok := found[id]; ok {
+			feed = append(feed, p)
+		}
+	}
+
+	if err := embedFeedOriginals(ctx, s.db, feed); err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
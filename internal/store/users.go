@@ -8,12 +8,16 @@ import (
 	"errors"
 	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	ErrDuplicateEmail    = errors.New("a user with that email already exists")
-	ErrDuplicateUsername = errors.New("a user with that username already exists")
+	ErrDuplicateEmail     = errors.New("a user with that email already exists")
+	ErrDuplicateUsername  = errors.New("a user with that username already exists")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrResetTokenExpired  = errors.New("password reset token has expired")
+	ErrEmailChangeExpired = errors.New("email confirmation token has expired")
 )
 
 type User struct {
@@ -25,6 +29,15 @@ type User struct {
 	IsActive  bool     `json:"is_active"`
 	RoleID    int64    `json:"role_id"`
 	Role      Role     `json:"role"`
+	Profile
+
+	// Moderation state, only loaded by the admin queries since restricted users aren't returned otherwise.
+	SuspendedUntil   *time.Time `json:"suspended_until,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	BannedAt         *time.Time `json:"banned_at,omitempty"`
+
+	// TokenGeneration is embedded in access tokens, bumping it revokes all of them.
+	TokenGeneration int `json:"-"`
 }
 
 type password struct {
@@ -44,6 +57,22 @@ func (p *password) Set(text string) error {
 	return nil
 }
 
+// Compare checks the plain text password against the stored hash.
+// It returns ErrInvalidCredentials when they don't match.
+func (p *password) Compare(text string) error {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(text))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return ErrInvalidCredentials
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
 type UserStore struct {
 	db *sql.DB
 }
@@ -90,26 +119,35 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	)
 
 	if err != nil {
-		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		return userConstraintError(err)
+	}
+
+	return nil
+}
+
+// userConstraintError maps unique violations on users to ErrDuplicateEmail and ErrDuplicateUsername.
+func userConstraintError(err error) error {
+	var pgErr *pq.Error
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		switch pgErr.Constraint {
+		case "users_email_key":
 			return ErrDuplicateEmail
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+		case "users_username_key":
 			return ErrDuplicateUsername
-		default:
-			return err
 		}
 	}
 
-	return nil
+	return err
 }
 
 func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	query := `
-		SELECT u.id, u.username, u.email, u.created_at,
+		SELECT u.id, u.username, u.email, u.created_at, u.token_generation,
+			u.display_name, u.bio, u.avatar_url, u.website, u.location,
 			r.id, r.name, r.description, r.level
 		FROM users u
 		JOIN roles r ON u.role_id = r.id
-		WHERE u.id = $1 AND u.is_active = true
+		WHERE u.id = $1 AND u.is_active = true AND ` + notRestricted + `
 		`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -122,6 +160,12 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 		&user.Username,
 		&user.Email,
 		&user.CreatedAt,
+		&user.TokenGeneration,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.Website,
+		&user.Location,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Description,
@@ -158,6 +202,28 @@ func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token strin
 	})
 }
 
+// CreateWithIdentity provisions an active user for an external account, without an
+// invitation to accept. Callers must only pass emails the identity provider verified.
+func (s *UserStore) CreateWithIdentity(ctx context.Context, user *User, identity *Identity) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.Create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		user.IsActive = true
+		if err := s.update(ctx, tx, user); err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		if err := createIdentity(ctx, tx, identity); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
 func (s *UserStore) Activate(ctx context.Context, token string) error {
 
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
@@ -248,7 +314,7 @@ func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 
 	_, err := tx.ExecContext(ctx, query, user.Username, user.Email, user.IsActive, user.ID)
 	if err != nil {
-		return err
+		return userConstraintError(err)
 	}
 
 	return nil
@@ -301,9 +367,9 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, username, email, password, created_at, is_active
+		SELECT id, username, email, password, created_at, is_active, token_generation
 		FROM users
-		WHERE email = $1 and is_active = true
+		WHERE email = $1 and is_active = true AND ` + notRestricted + `
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -318,6 +384,7 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Password.hash,
 		&user.CreatedAt,
 		&user.IsActive,
+		&user.TokenGeneration,
 	)
 
 	if err != nil {
@@ -332,3 +399,402 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 	return &user, nil
 
 }
+
+// IncrementTokenGeneration invalidates every access token issued to the user so far.
+func (s *UserStore) IncrementTokenGeneration(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET token_generation = token_generation + 1 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreatePasswordReset stores a reset token for the user, replacing any previous one.
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deletePasswordResets(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO password_resets (token, user_id, expiry) VALUES ($1, $2, $3)`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ResetPassword consumes the reset token and sets the new password.
+// The token generation is bumped in the same transaction, so every access token issued before stops working.
+func (s *UserStore) ResetPassword(ctx context.Context, token, newPassword string) (*User, error) {
+	var user *User
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// 1. consume the token and find the user it belongs to
+		u, err := s.consumePasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		// 2. hash and store the new password
+		if err := u.Password.Set(newPassword); err != nil {
+			return err
+		}
+
+		if err := s.updatePassword(ctx, tx, u); err != nil {
+			return err
+		}
+
+		// 3. drop any other token of the user
+		if err := s.deletePasswordResets(ctx, tx, u.ID); err != nil {
+			return err
+		}
+
+		user = u
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// consumePasswordReset deletes the reset token and returns its user. Deleting the row is what
+// makes the token single-use: a concurrent reset with the same token waits on the row lock,
+// then finds it gone.
+func (s *UserStore) consumePasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+		WITH pr AS (
+			DELETE FROM password_resets WHERE token = $1
+			RETURNING user_id, expiry
+		)
+		SELECT u.id, u.username, u.email, u.created_at, u.is_active, pr.expiry
+		FROM users u
+		JOIN pr ON (u.id = pr.user_id)
+	`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	var expiry time.Time
+
+	err := tx.QueryRowContext(ctx, query, hashToken).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+		&expiry,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if time.Now().After(expiry) {
+		return nil, ErrResetTokenExpired
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) updatePassword(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `
+		UPDATE users
+		SET password = $1, token_generation = token_generation + 1
+		WHERE id = $2
+		RETURNING token_generation
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(ctx, query, user.Password.hash, user.ID).Scan(&user.TokenGeneration)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RenewInvitation replaces the invitations of a user that hasn't activated the account yet.
+// It returns ErrNotFound when there is no such user for the email.
+func (s *UserStore) RenewInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error) {
+	var user *User
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		u, err := s.getInactiveByEmail(ctx, tx, email)
+		if err != nil {
+			return err
+		}
+
+		if err := s.deleteUserInvitations(ctx, tx, u.ID); err != nil {
+			return err
+		}
+
+		if err := s.createUserInvitation(ctx, tx, token, invitationExp, u.ID); err != nil {
+			return err
+		}
+
+		user = u
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) getInactiveByEmail(ctx context.Context, tx *sql.Tx, email string) (*User, error) {
+	query := `
+		SELECT id, username, email, created_at, is_active
+		FROM users
+		WHERE email = $1 AND is_active = false
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// DeleteExpiredInvitations purges invitations that can no longer be used and returns how many were removed.
+func (s *UserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	query := `DELETE FROM user_invitations WHERE expiry < NOW()`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// DeleteUnactivated removes users that registered more than gracePeriod ago, never activated
+// their account and hold no valid invitation, freeing their email and username.
+func (s *UserStore) DeleteUnactivated(ctx context.Context, gracePeriod time.Duration) (int64, error) {
+	query := `
+		DELETE FROM users u
+		WHERE u.is_active = false
+			AND u.created_at < NOW() - make_interval(secs => $1)
+			AND NOT EXISTS (
+				SELECT 1 FROM user_invitations ui
+				WHERE ui.user_id = u.id AND ui.expiry > NOW()
+			)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, gracePeriod.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// UpdateProfile saves the fields users can change about themselves.
+func (s *UserStore) UpdateProfile(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users
+		SET username = $1, display_name = $2, bio = $3, avatar_url = $4, website = $5, location = $6
+		WHERE id = $7
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		user.Username,
+		user.DisplayName,
+		user.Bio,
+		user.AvatarURL,
+		user.Website,
+		user.Location,
+		user.ID,
+	)
+	if err != nil {
+		return userConstraintError(err)
+	}
+
+	return nil
+}
+
+// ChangePassword sets a new password, logging the user out everywhere like a reset does.
+func (s *UserStore) ChangePassword(ctx context.Context, user *User, newPassword string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := user.Password.Set(newPassword); err != nil {
+			return err
+		}
+
+		if err := s.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		// pending reset links would bring the old password flow back
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// CreateEmailChange stores a pending email change, replacing the previous one.
+// The email is only changed once the token sent to the new address is confirmed.
+func (s *UserStore) CreateEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var taken bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`, newEmail).Scan(&taken)
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return ErrDuplicateEmail
+		}
+
+		if err := s.deleteEmailChanges(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO email_changes (token, user_id, new_email, expiry) VALUES ($1, $2, $3, $4)`
+
+		_, err = tx.ExecContext(ctx, query, token, userID, newEmail, time.Now().Add(exp))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ConfirmEmailChange consumes the token and moves the user to the new email.
+// It returns the id of the user whose email changed.
+func (s *UserStore) ConfirmEmailChange(ctx context.Context, token string) (int64, error) {
+	var userID int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT user_id, new_email, expiry FROM email_changes WHERE token = $1`
+
+		hash := sha256.Sum256([]byte(token))
+		hashToken := hex.EncodeToString(hash[:])
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var (
+			newEmail string
+			expiry   time.Time
+		)
+		err := tx.QueryRowContext(ctx, query, hashToken).Scan(&userID, &newEmail, &expiry)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		if time.Now().After(expiry) {
+			return ErrEmailChangeExpired
+		}
+
+		// the address may have been registered since the change was requested
+		_, err = tx.ExecContext(ctx, `UPDATE users SET email = $1 WHERE id = $2`, newEmail, userID)
+		if err != nil {
+			return userConstraintError(err)
+		}
+
+		return s.deleteEmailChanges(ctx, tx, userID)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (s *UserStore) deleteEmailChanges(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM email_changes WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}